@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+var (
+	detectBare    bool  // 由 --detect-bare 设置，识别没有 mime_type 和 data: 前缀的 base64 数据
+	minBareLength = 100 // 由 --min-bare-length 设置，识别为裸 base64 数据的最小长度
+)
+
+// bareSniffLength 识别裸 base64 数据时解码的前缀长度（字符数，解码后 129 字节，足够识别 SVG）
+const bareSniffLength = 172
+
+// sniffBareBase64 判断字符串是否是没有任何标记的 base64 图片（如 OpenAI 的 b64_json、
+// Chrome DevTools 截图的 data 字段、Ollama / Stable Diffusion 的 images 数组）
+// 字符串需要足够长、是合法的 base64，并且解码后开头的字节是已知的图片格式，返回对应的 MIME 类型
+func sniffBareBase64(str string) (string, bool) {
+	if !detectBare || len(str) < minBareLength || !isBase64String(str) {
+		return "", false
+	}
+
+	// 只解码开头的一部分，检测文件魔数
+	head := decodeBase64Prefix(str[:min(bareSniffLength, len(str))])
+	if head == nil {
+		return "", false
+	}
+
+	ext := detectImageType(head)
+	if ext == "" || (ext == ".bmp" && !looksLikeBMP(head)) {
+		return "", false
+	}
+	return mimeTypeForExtension(ext), true
+}
+
+// isBase64String 检查字符串是否只包含 base64 字符（标准或 URL 安全的字母表），且长度和填充正确
+// 没有填充时长度除以 4 的余数不能为 1
+func isBase64String(str string) bool {
+	padded := strings.HasSuffix(str, "=")
+	if (padded && len(str)%4 != 0) || len(str)%4 == 1 {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '/', c == '-', c == '_':
+		case c == '=' && i >= len(str)-2:
+			// 填充只能出现在最后两位
+			if i == len(str)-2 && str[i+1] != '=' {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikeBMP 进一步检查 BMP 文件头（"BM" 只有两个字节，任何以 Qk 开头的 base64 都会匹配）
+func looksLikeBMP(head []byte) bool {
+	if len(head) < 18 {
+		return false
+	}
+	// 偏移 14 处是 DIB 头的长度，只有几种合法取值
+	switch binary.LittleEndian.Uint32(head[14:18]) {
+	case 12, 40, 52, 56, 64, 108, 124:
+		return true
+	}
+	return false
+}
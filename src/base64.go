@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+// base64Variant 描述输入数据使用的 base64 变体
+type base64Variant struct {
+	URLSafe  bool // 使用 URL 安全的字母表（- 和 _ 代替 + 和 /）
+	Unpadded bool // 缺少结尾的 = 填充
+	Wrapped  bool // 包含换行、空格或转义的 \n（如每 76 或 64 列换行的 MIME 正文）
+}
+
+// String 返回变体的名称，如 standard、url-safe,unpadded、standard,wrapped
+func (v base64Variant) String() string {
+	parts := []string{"standard"}
+	if v.URLSafe {
+		parts[0] = "url-safe"
+	}
+	if v.Unpadded {
+		parts = append(parts, "unpadded")
+	}
+	if v.Wrapped {
+		parts = append(parts, "wrapped")
+	}
+	return strings.Join(parts, ",")
+}
+
+// base64Normalizer 把各种 base64 变体规范化为带填充的标准 base64：
+// 删除空白和转义的 \n、\r，把 URL 安全字母表转换为标准字母表，在结尾补全缺少的填充
+type base64Normalizer struct {
+	r       io.Reader
+	variant base64Variant
+	buf     [4096]byte
+	out     []byte
+	pending []byte
+	count   int  // 已输出的数据字符数（不含填充）
+	padded  bool // 是否已经遇到 = 填充
+	escape  bool // 上一个字节是反斜杠
+	done    bool
+}
+
+// Read 实现 io.Reader 接口
+func (n *base64Normalizer) Read(p []byte) (int, error) {
+	for len(n.pending) == 0 {
+		if n.done {
+			return 0, io.EOF
+		}
+		m, err := n.r.Read(n.buf[:])
+		n.out = n.out[:0]
+		for _, c := range n.buf[:m] {
+			n.normalize(c)
+		}
+		if err == io.EOF {
+			n.finish()
+			n.done = true
+		} else if err != nil {
+			return 0, err
+		}
+		n.pending = n.out
+	}
+	c := copy(p, n.pending)
+	n.pending = n.pending[c:]
+	return c, nil
+}
+
+// normalize 处理一个输入字节
+func (n *base64Normalizer) normalize(c byte) {
+	if n.escape {
+		n.escape = false
+		if c == 'n' || c == 'r' {
+			n.variant.Wrapped = true
+			return
+		}
+		// 不是换行的转义，保留反斜杠，由解码器报告错误
+		n.out = append(n.out, '\\')
+	}
+
+	switch c {
+	case '\\':
+		n.escape = true
+	case ' ', '\t', '\r', '\n':
+		n.variant.Wrapped = true
+	case '-':
+		n.variant.URLSafe = true
+		n.emit('+')
+	case '_':
+		n.variant.URLSafe = true
+		n.emit('/')
+	case '=':
+		n.padded = true
+		n.out = append(n.out, c)
+	default:
+		n.emit(c)
+	}
+}
+
+// emit 输出一个数据字符
+func (n *base64Normalizer) emit(c byte) {
+	n.out = append(n.out, c)
+	n.count++
+}
+
+// finish 输入结束时补全缺少的填充
+func (n *base64Normalizer) finish() {
+	if n.escape {
+		n.out = append(n.out, '\\')
+	}
+	if n.padded {
+		return
+	}
+	switch n.count % 4 {
+	case 2:
+		n.out = append(n.out, "=="...)
+		n.variant.Unpadded = true
+	case 3:
+		n.out = append(n.out, '=')
+		n.variant.Unpadded = true
+	}
+}
+
+// tolerantDecoder 兼容标准、URL 安全、无填充和换行的 base64 解码器
+type tolerantDecoder struct {
+	norm *base64Normalizer
+	dec  io.Reader
+}
+
+// newTolerantDecoder 返回从 r 中读取任意 base64 变体并输出解码结果的 Reader
+func newTolerantDecoder(r io.Reader) *tolerantDecoder {
+	norm := &base64Normalizer{r: r}
+	return &tolerantDecoder{norm: norm, dec: base64.NewDecoder(base64.StdEncoding, norm)}
+}
+
+// Read 实现 io.Reader 接口
+func (d *tolerantDecoder) Read(p []byte) (int, error) {
+	return d.dec.Read(p)
+}
+
+// Variant 返回已读取部分使用的 base64 变体（读取完成后才是完整的结果）
+func (d *tolerantDecoder) Variant() base64Variant {
+	return d.norm.variant
+}
+
+// Encoding 实现 payloadDecoder 接口
+func (d *tolerantDecoder) Encoding() string {
+	return d.Variant().String()
+}
+
+// decodeBase64String 解码任意变体的 base64 字符串，返回解码结果和识别出的变体
+func decodeBase64String(s string) ([]byte, base64Variant, error) {
+	dec := newTolerantDecoder(strings.NewReader(s))
+	data, err := io.ReadAll(dec)
+	return data, dec.Variant(), err
+}
+
+// decodeBase64Prefix 解码 base64 数据的开头部分（用于检测文件类型），截断到完整的 4 字符分组，
+// 无法解码时返回 nil
+func decodeBase64Prefix(s string) []byte {
+	norm := &base64Normalizer{}
+	for i := 0; i < len(s); i++ {
+		norm.normalize(s[i])
+	}
+	prefix := strings.TrimRight(string(norm.out), "=")
+	prefix = prefix[:len(prefix)/4*4]
+	data, err := base64.StdEncoding.DecodeString(prefix)
+	if err != nil {
+		return nil
+	}
+	return data
+}
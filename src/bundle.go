@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bundleMarkdownRe 匹配 Markdown 中的图片引用（格式与 embeddedDataURLRe 的前三种相同，目标不限于 Data URL）
+//   - 行内图片: ![alt](path.png "title") 或 ![alt](<path with spaces.png>)，分组 2 / 3 为（尖括号中的）目标
+//   - 引用式图片的定义: [label]: path.png，分组 4 为标签，分组 5 / 6 为（尖括号中的）目标
+//   - 内嵌的 HTML 标签: <img src="path.png">，分组 7 为整个标签
+var bundleMarkdownRe = regexp.MustCompile(
+	`!\[([^\]]*)\]\(\s*(?:<([^<>\n]*)>|([^\s()<>]+))` + markdownTitlePattern + `\s*\)` +
+		`|(?m:^)[ ]{0,3}\[([^\]]+)\]:[ \t]*(?:\r?\n[ \t]*)?(?:<([^<>\n]*)>|(\S+))` +
+		`|(<[A-Za-z][\w:-]*\s[^<>]*>)`)
+
+// inlineCodeRe 匹配 Markdown 的行内代码
+var inlineCodeRe = regexp.MustCompile("`[^`\n]+`")
+
+var (
+	maxImageSize int64 // 由 --max-image-size 设置，超过该大小的图片保留原引用（0 为不限制）
+	skipRemote   bool  // 由 --skip-remote 设置，不下载 http/https 图片
+)
+
+// errImageTooLarge 图片超过 --max-image-size
+var errImageTooLarge = errors.New("image exceeds --max-image-size")
+
+// bundler 把文档中引用的本地或远程图片内联为 Data URL
+type bundler struct {
+	baseDir string            // 相对路径的基准目录（输入文件所在的目录）
+	cache   map[string]string // 已处理的图片（文件路径或 URL → Data URL，无法内联时为空字符串）
+}
+
+// runBundle 执行 bundle 模式：把 Markdown/HTML/CSS 中引用的图片内联为 Data URL，输出单个可移植的文件
+func runBundle(data []byte) {
+	b := &bundler{baseDir: ".", cache: make(map[string]string)}
+	if inputName != "" {
+		b.baseDir = filepath.Dir(inputName)
+	}
+
+	var output []byte
+	var err error
+	switch format := detectInputFormat(data); format {
+	case inputHTML, inputCSS:
+		output, err = rewriteMarkup(data, format, b.resolveMarkup)
+	default:
+		var text string
+		text, err = b.bundleMarkdown(string(data))
+		output = []byte(text)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error bundling images: %v\n", err)
+		os.Exit(exitIOError)
+	}
+	os.Stdout.Write(output)
+
+	if failedCount > 0 {
+		os.Exit(exitPartial)
+	}
+}
+
+// bundleMarkdown 内联 Markdown 中的图片：行内图片、引用式图片的定义和内嵌 HTML 标签，
+// 只替换图片的目标，代码块和行内代码中的内容不做处理
+func (b *bundler) bundleMarkdown(text string) (string, error) {
+	code := markdownCodeRanges(text)
+	labels := markdownReferenceAlts(text)
+
+	var result strings.Builder
+	last := 0
+	for _, loc := range bundleMarkdownRe.FindAllStringSubmatchIndex(text, -1) {
+		if inRanges(code, loc[0]) {
+			continue
+		}
+
+		var start, end int
+		var replacement string
+		switch {
+		case loc[2] >= 0 || loc[8] >= 0:
+			if loc[8] >= 0 {
+				// 只处理被 ![alt][label] 引用的定义，普通链接的定义保持不变
+				if _, ok := labels[normalizeLabel(text[loc[8]:loc[9]])]; !ok {
+					continue
+				}
+			}
+			start, end = firstGroup(loc, 2, 3, 5, 6)
+			dataURL, ok, err := b.inline(text[start:end])
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				continue
+			}
+			replacement = dataURL
+		default:
+			start, end = loc[14], loc[15]
+			m := &markupRewriter{data: []byte(text[start:end]), resolve: b.resolveMarkup}
+			if _, _, err := m.scanTag(0); err != nil {
+				return "", err
+			}
+			if len(m.edits) == 0 {
+				continue
+			}
+			replacement = string(applyByteEdits(m.data, m.edits))
+		}
+
+		result.WriteString(text[last:start])
+		result.WriteString(replacement)
+		last = end
+	}
+	result.WriteString(text[last:])
+	return result.String(), nil
+}
+
+// resolveMarkup 实现 markupResolver：把 HTML/CSS 中的图片引用替换为 Data URL
+func (b *bundler) resolveMarkup(ref string, offset int) (interface{}, bool, error) {
+	dataURL, ok, err := b.inline(ref)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return dataURL, true, nil
+}
+
+// inline 读取引用的图片并返回 Data URL，不是图片引用或无法读取时返回 false
+// 相对路径以输入文件所在目录为基准；data:、mailto: 等其他 scheme、#锚点和扩展名不是图片的链接不处理；
+// 文件不存在、读取失败或内容不是图片时按 --on-error 处理（fail 返回错误，否则输出警告并保留原引用）
+func (b *bundler) inline(ref string) (string, bool, error) {
+	ref = strings.TrimSpace(ref)
+	u, err := url.Parse(ref)
+	if ref == "" || err != nil {
+		return "", false, nil
+	}
+
+	remote := false
+	var key string
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if skipRemote {
+			return "", false, nil
+		}
+		remote = true
+		key = ref
+	case "file":
+		key = filepath.FromSlash(u.Path)
+	case "":
+		if u.Path == "" {
+			return "", false, nil
+		}
+		key = filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(key) {
+			key = filepath.Join(b.baseDir, key)
+		}
+	default:
+		return "", false, nil
+	}
+	if ext := strings.ToLower(path.Ext(u.Path)); ext != "" && !strings.HasPrefix(mimeTypeForExtension(ext), "image/") {
+		return "", false, nil
+	}
+
+	if dataURL, ok := b.cache[key]; ok {
+		return dataURL, dataURL != "", nil
+	}
+	b.cache[key] = ""
+
+	var data []byte
+	if remote {
+		data, err = fetchImage(ref)
+	} else {
+		data, err = readImageFile(key)
+	}
+	if errors.Is(err, errImageTooLarge) {
+		fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", ref, err)
+		return "", false, nil
+	}
+	ext := detectImageType(data)
+	if err == nil && ext == "" {
+		err = errors.New("not a supported image")
+	}
+	if err != nil {
+		failedCount++
+		err = fmt.Errorf("failed to bundle %s: %w", ref, err)
+		if errorPolicy == onErrorFail {
+			return "", false, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return "", false, nil
+	}
+
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeTypeForExtension(ext), base64.StdEncoding.EncodeToString(data))
+	b.cache[key] = dataURL
+	return dataURL, true, nil
+}
+
+// readImageFile 读取本地图片文件，超过 --max-image-size 时返回 errImageTooLarge
+func readImageFile(filename string) ([]byte, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if maxImageSize > 0 && info.Size() > maxImageSize {
+		return nil, fmt.Errorf("%w (%s)", errImageTooLarge, formatSize(info.Size()))
+	}
+	return os.ReadFile(filename)
+}
+
+// fetchImage 下载远程图片，超过 --max-image-size 时返回 errImageTooLarge
+func fetchImage(urlStr string) ([]byte, error) {
+	resp, err := http.Get(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	}
+
+	body := io.Reader(resp.Body)
+	if maxImageSize > 0 {
+		body = io.LimitReader(resp.Body, maxImageSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if maxImageSize > 0 && int64(len(data)) > maxImageSize {
+		return nil, errImageTooLarge
+	}
+	return data, nil
+}
+
+// parseByteSize 解析大小参数，如 500000、500K、2M、1G（单位为 1024 的倍数，可以带 B 或 iB 后缀）
+func parseByteSize(value string) (int64, error) {
+	s := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B"), "I")
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected bytes or a number with K, M or G)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// markdownCodeRanges 返回 Markdown 中围栏代码块（``` 或 ~~~）和行内代码的字节范围，
+// 其中的图片语法通常只是示例
+func markdownCodeRanges(text string) [][2]int {
+	var ranges [][2]int
+	fence, fenceStart := "", 0
+	for i := 0; i < len(text); {
+		end := lineEnd(text, i)
+		line := strings.TrimLeft(text[i:end], " ")
+		switch {
+		case fence == "" && (strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")):
+			fence, fenceStart = line[:3], i
+		case fence != "" && strings.HasPrefix(line, fence):
+			ranges = append(ranges, [2]int{fenceStart, end})
+			fence = ""
+		}
+		i = end
+	}
+	if fence != "" {
+		// 没有结束的代码块一直到文档结尾
+		ranges = append(ranges, [2]int{fenceStart, len(text)})
+	}
+	for _, loc := range inlineCodeRe.FindAllStringIndex(text, -1) {
+		ranges = append(ranges, [2]int{loc[0], loc[1]})
+	}
+	return ranges
+}
+
+// inRanges 判断 offset 是否在某个范围内
+func inRanges(ranges [][2]int, offset int) bool {
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// firstGroup 返回 groups 中第一个匹配到的分组的字节范围
+func firstGroup(loc []int, groups ...int) (int, int) {
+	for _, g := range groups {
+		if loc[2*g] >= 0 {
+			return loc[2*g], loc[2*g+1]
+		}
+	}
+	return -1, -1
+}
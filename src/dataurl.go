@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// dataURLHeaderPattern 匹配 Data URL 的媒体类型和参数，如 image/png;name=chart.png（媒体类型可以省略）
+// 与浏览器一致，也接受没有值的参数（如 CSS 中常见的 image/svg+xml;utf8）
+const dataURLHeaderPattern = `(?:` + mimeTypePattern + `)?(?:;[\w.+-]+(?:=(?:"[^"]*"|[^;,\s"]*))?)*`
+
+// dataURLParamRe 匹配 Data URL 中的一个参数: ;name=value、;name="value" 或没有值的 ;name
+var dataURLParamRe = regexp.MustCompile(`^;([\w.+-]+)(?:=("[^"]*"|[^;,"]*))?`)
+
+// mimeTypeRe 匹配完整的 MIME 类型
+var mimeTypeRe = regexp.MustCompile(`^` + mimeTypePattern + `$`)
+
+// dataURL 解析后的 Data URL（RFC 2397）: data:[<mediatype>][;base64],<data>
+type dataURL struct {
+	MimeType string            // 小写的 MIME 类型，省略时为 text/plain
+	Params   map[string]string // 媒体类型参数（键为小写，值已解码），如 charset、name
+	Base64   bool              // 数据是否是 base64 编码，否则为百分号编码
+	Data     string            // 逗号之后的原始数据
+}
+
+// parseDataURL 解析 Data URL，MIME 类型和参数名不区分大小写，参数值可以使用百分号编码或引号
+func parseDataURL(s string) (dataURL, bool) {
+	if len(s) < 5 || !strings.EqualFold(s[:5], "data:") {
+		return dataURL{}, false
+	}
+	header, data, ok := strings.Cut(s[5:], ",")
+	if !ok {
+		return dataURL{}, false
+	}
+
+	u := dataURL{MimeType: "text/plain", Params: make(map[string]string), Data: data}
+
+	// 媒体类型
+	mimeType := header
+	if i := strings.IndexByte(header, ';'); i >= 0 {
+		mimeType, header = header[:i], header[i:]
+	} else {
+		header = ""
+	}
+	if mimeType != "" {
+		if !mimeTypeRe.MatchString(mimeType) {
+			return dataURL{}, false
+		}
+		u.MimeType = strings.ToLower(mimeType)
+	}
+
+	// 参数，;base64 必须是最后一个
+	for header != "" {
+		if strings.EqualFold(header, ";base64") {
+			u.Base64 = true
+			break
+		}
+		m := dataURLParamRe.FindStringSubmatch(header)
+		if m == nil {
+			return dataURL{}, false
+		}
+		u.Params[strings.ToLower(m[1])] = percentDecode(strings.Trim(m[2], `"`))
+		header = header[len(m[0]):]
+	}
+	return u, true
+}
+
+// FileName 返回 name 或 filename 参数指定的文件名（去掉目录部分并清理为安全的文件名），没有时返回空字符串
+func (u dataURL) FileName() string {
+	name := u.Params["name"]
+	if name == "" {
+		name = u.Params["filename"]
+	}
+	return sanitizeFileName(name)
+}
+
+// sanitizeFileName 把外部提供的文件名清理为安全的文件名：只保留最后一层路径，
+// 不安全的字符替换为 _，为空或只有 . 时返回空字符串
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if strings.Trim(name, ". /") == "" {
+		return ""
+	}
+	return sanitizeRecordName(name)
+}
+
+// saveDataURL 保存 Data URL 中的数据，有 name/filename 参数时用作文件名
+func saveDataURL(u dataURL, outputDir string, src blobSource) (savedBlob, error) {
+	src.Name = u.FileName()
+	return saveEncodedStream(strings.NewReader(u.Data), u.encoding(), u.MimeType, outputDir, src)
+}
+
+// extractDataURL 保存 Data URL 中的数据并返回替换值和是否替换，提取失败时按 --on-error 处理
+func extractDataURL(u dataURL, outputDir string, src blobSource) (interface{}, bool, error) {
+	blob, err := saveDataURL(u, outputDir, src)
+	if err != nil {
+		return handleExtractError(src, err)
+	}
+	return replacementValue(blob), true, nil
+}
+
+// encoding 返回数据部分的编码方式，用于选择解码器
+func (u dataURL) encoding() payloadEncoding {
+	if u.Base64 {
+		return base64Payload
+	}
+	return percentPayload
+}
+
+// payloadEncoding 数据部分的编码方式
+type payloadEncoding int
+
+const (
+	base64Payload  payloadEncoding = iota // base64（兼容各种变体，见 tolerantDecoder）
+	percentPayload                        // 百分号编码（如未 base64 编码的 SVG）
+)
+
+// payloadDecoder 从编码后的数据中读取解码结果，并报告输入使用的编码
+type payloadDecoder interface {
+	io.Reader
+	Encoding() string
+}
+
+// newPayloadDecoder 返回指定编码方式的解码器
+func newPayloadDecoder(r io.Reader, encoding payloadEncoding) payloadDecoder {
+	if encoding == percentPayload {
+		return &percentDecoder{r: bufio.NewReader(r)}
+	}
+	return newTolerantDecoder(r)
+}
+
+// percentDecoder 解码百分号编码的数据，不合法的 % 转义按原样保留（与浏览器一致）
+type percentDecoder struct {
+	r *bufio.Reader
+}
+
+// Read 实现 io.Reader 接口
+func (d *percentDecoder) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		if c == '%' {
+			if hex, err := d.r.Peek(2); err == nil && isHexDigit(hex[0]) && isHexDigit(hex[1]) {
+				c = unhex(hex[0])<<4 | unhex(hex[1])
+				d.r.Discard(2)
+			}
+		}
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
+
+// Encoding 实现 payloadDecoder 接口
+func (d *percentDecoder) Encoding() string {
+	return "percent-encoded"
+}
+
+// percentDecode 解码百分号编码的字符串，不合法的 % 转义按原样保留
+func percentDecode(s string) string {
+	decoded, _ := io.ReadAll(&percentDecoder{r: bufio.NewReader(strings.NewReader(s))})
+	return string(decoded)
+}
+
+// isHexDigit 判断是否是十六进制数字
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// unhex 返回十六进制数字的值
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -90,8 +89,8 @@ func decodeBase64File(filename, outputDir string) error {
 		}
 	}
 
-	// 解码 base64
-	imageData, err := base64.StdEncoding.DecodeString(base64Data)
+	// 解码 base64（兼容 URL 安全、无填充和换行的 base64）
+	imageData, variant, err := decodeBase64String(strings.TrimSpace(base64Data))
 	if err != nil {
 		return fmt.Errorf("failed to decode base64: %w", err)
 	}
@@ -152,5 +151,8 @@ func decodeBase64File(filename, outputDir string) error {
 	}
 
 	fmt.Printf("Decoded image saved to: %s\n", outputPath)
+	if variant != (base64Variant{}) {
+		fmt.Printf("Base64 variant: %s\n", variant)
+	}
 	return nil
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// docKind YAML/TOML 节点的类型
+type docKind int
+
+const (
+	docScalar docKind = iota
+	docMapping
+	docSequence
+)
+
+// docNode YAML/TOML 文档中的一个值和它在文档中的字节范围，用于只改写提取出的数据
+type docNode struct {
+	kind       docKind
+	value      interface{} // 标量的值: string、float64、bool 或 nil
+	style      byte        // 字符串的书写方式: 0 为无引号，'"'、'\'' 为单行引号，'|' 为块标量或多行字符串
+	indent     int         // YAML 块标量内容行的缩进（没有内容行时为 0）
+	start, end int         // 值的字节范围
+	entries    []docEntry  // 映射的字段或序列的元素（按文档顺序）
+	inline     bool        // 元素在同一行中相邻：流式集合（YAML 的 [] 和 {}、TOML 的数组和内联表）或紧凑的块映射（- key: value）
+}
+
+// docEntry 映射的一个字段或序列的一个元素
+type docEntry struct {
+	key        string // 序列元素为空字符串
+	node       *docNode
+	start, end int // 字段的字节范围（块集合中为整行，inline 集合中为键的开头到值的结尾），隐式创建的表为 -1
+}
+
+// docRewriter 比较提取前后的值，为发生变化的标量生成字节改写
+type docRewriter struct {
+	edits []byteEdit
+	// encode 返回替换标量 n 的内容，value 是提取后的值（字符串、blobObject 或 nil）
+	encode func(n *docNode, value interface{}) string
+	// removeElements 为 true 时删除值为 null 的序列元素（TOML 没有 null），否则写入 null
+	removeElements bool
+}
+
+// runDocument 以 YAML 或 TOML 处理输入：与 JSON 相同的提取规则，只改写提取出的数据，
+// 注释、键的顺序和其他字节保持不变；无法解析时作为纯文本处理
+func runDocument(data []byte, format, outputDir string, pretty bool) {
+	if pretty {
+		fmt.Fprintf(os.Stderr, "Warning: --pretty flag only applies to JSON input, ignoring\n")
+	}
+	output, err := rewriteDocument(data, format, outputDir)
+	if err == errInvalidDocument {
+		runText(data, outputDir, false)
+		return
+	}
+	if err != nil {
+		abortExtraction("processing images", err)
+	}
+	os.Stdout.Write(output)
+}
+
+// errInvalidDocument 输入不是可以解析的 YAML/TOML/XML 文档（已经输出警告）
+var errInvalidDocument = fmt.Errorf("invalid document")
+
+// rewriteDocument 解析 YAML 或 TOML 文档，对每个文档执行 processImages，返回改写后的文档；XML 由 rewriteXML 处理
+func rewriteDocument(data []byte, format, outputDir string) ([]byte, error) {
+	if format == inputXML {
+		return rewriteXML(data, outputDir)
+	}
+	var docs []*docNode
+	var err error
+	rw := &docRewriter{}
+	if format == inputTOML {
+		var doc *docNode
+		doc, err = parseTOML(data)
+		docs = []*docNode{doc}
+		rw.encode = encodeTOMLScalar
+		rw.removeElements = true
+	} else {
+		docs, err = parseYAML(data)
+		rw.encode = encodeYAMLScalar
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s (%v), processing as text\n", format, err)
+		return nil, errInvalidDocument
+	}
+
+	// 多个 YAML 文档与 JSON Lines 一样逐条处理，单个文档不按 key/custom_id 区分记录
+	for _, doc := range docs {
+		value := doc.toValue()
+		if len(docs) > 1 {
+			setCurrentRecord(value)
+		}
+		if err := processImages(value, "", outputDir); err != nil {
+			return nil, err
+		}
+		rw.diff(doc, value)
+	}
+	currentRecord = ""
+	return applyByteEdits(data, rw.edits), nil
+}
+
+// toValue 把节点转换为 processImages 使用的值（与 json.Unmarshal 的结果相同的类型）
+func (n *docNode) toValue() interface{} {
+	switch n.kind {
+	case docMapping:
+		m := make(map[string]interface{}, len(n.entries))
+		for _, e := range n.entries {
+			m[e.key] = e.node.toValue()
+		}
+		return m
+	case docSequence:
+		s := make([]interface{}, len(n.entries))
+		for i, e := range n.entries {
+			s[i] = e.node.toValue()
+		}
+		return s
+	}
+	return n.value
+}
+
+// find 返回映射中键为 key 的字段
+func (n *docNode) find(key string) *docEntry {
+	for i := range n.entries {
+		if n.entries[i].key == key {
+			return &n.entries[i]
+		}
+	}
+	return nil
+}
+
+// diff 比较节点和 processImages 处理后的值：被删除的字段删除整个字段，改变的字符串替换为新的值
+func (rw *docRewriter) diff(n *docNode, value interface{}) {
+	switch n.kind {
+	case docMapping:
+		m, _ := value.(map[string]interface{})
+		removed := make(map[int]bool)
+		for i, e := range n.entries {
+			v, ok := m[e.key]
+			if !ok {
+				removed[i] = true
+				continue
+			}
+			rw.diff(e.node, v)
+		}
+		rw.removeEntries(n, removed)
+
+	case docSequence:
+		s, _ := value.([]interface{})
+		removed := make(map[int]bool)
+		for i, e := range n.entries {
+			if i >= len(s) {
+				break
+			}
+			if s[i] == nil && rw.removeElements && e.node.value != nil {
+				removed[i] = true
+				continue
+			}
+			rw.diff(e.node, s[i])
+		}
+		rw.removeEntries(n, removed)
+
+	default:
+		str, ok := n.value.(string)
+		if !ok {
+			return
+		}
+		if newStr, ok := value.(string); ok && newStr == str {
+			return
+		}
+		rw.edits = append(rw.edits, byteEdit{
+			start:       int64(n.start),
+			end:         int64(n.end),
+			replacement: []byte(rw.encode(n, value)),
+		})
+	}
+}
+
+// removeEntries 删除映射的字段或序列的元素（removed 为下标）
+// inline 集合中不是最后一个的元素删除到下一个元素之前（包括逗号或换行），
+// 最后一个元素从上一个保留的元素的结尾开始删除，相邻的删除范围不会重叠
+func (rw *docRewriter) removeEntries(n *docNode, removed map[int]bool) {
+	for i, e := range n.entries {
+		if !removed[i] || e.start < 0 {
+			continue
+		}
+		start, end := e.start, e.end
+		if n.inline {
+			switch {
+			case i+1 < len(n.entries):
+				end = n.entries[i+1].start
+			case i > 0 && !removed[i-1]:
+				start = n.entries[i-1].node.end
+			}
+		}
+		rw.edits = append(rw.edits, byteEdit{start: int64(start), end: int64(end)})
+	}
+}
+
+// normalizeJSONValue 把替换值（如 blobObject）转换为按字段顺序排列的 JSON token，用于编码为其他格式
+func normalizeJSONValue(value interface{}) *json.Decoder {
+	dec := json.NewDecoder(bytes.NewReader(encodeJSONValue(value)))
+	dec.UseNumber()
+	return dec
+}
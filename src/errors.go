@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 提取失败时的处理方式（--on-error），对结构化格式、Data URL 和文本中的数据一致
+const (
+	onErrorFail = "fail" // 立即停止，退出码为 1（数据无效）或 3（I/O 错误）
+	onErrorSkip = "skip" // 输出警告，删除无法提取的字段（数组元素替换为 null，文本中删除该数据）
+	onErrorKeep = "keep" // 输出警告，保留原始数据（默认）
+)
+
+// 提取模式（JSON/文本处理）的退出码
+const (
+	exitOK           = 0 // 成功提取了数据
+	exitInvalidInput = 1 // 参数或输入无效，包括 JSON 语法错误和无法解码的 base64
+	exitPartial      = 2 // 部分数据提取失败（--on-error skip 或 keep），其他数据提取成功
+	exitIOError      = 3 // 读取输入或写入文件失败（包括 skip 或 keep 时的写入失败）
+	exitNothingFound = 4 // 输入中没有可提取的数据
+)
+
+// errInvalidJSON 输入不是合法的 JSON
+var errInvalidJSON = errors.New("invalid JSON")
+
+var (
+	errorPolicy    = onErrorKeep // 由 --on-error 设置
+	transactional  bool          // 由 --transactional 设置，运行失败时删除本次写入的文件
+	extractedCount int           // 本次运行成功提取的数据块数量
+	failedCount    int           // 本次运行提取失败的数据块数量
+	ioFailedCount  int           // 其中因为读写文件失败（而不是数据无效）的数量
+	createdFiles   []string      // 本次运行新建的文件（用于回滚）
+	createdDirs    []string      // 本次运行新建的目录（用于回滚）
+)
+
+// validateErrorPolicy 检查 --on-error 参数
+func validateErrorPolicy() error {
+	switch errorPolicy {
+	case onErrorFail, onErrorSkip, onErrorKeep:
+		return nil
+	}
+	return fmt.Errorf("invalid --on-error value %q (expected fail, skip or keep)", errorPolicy)
+}
+
+// extractBlob 保存 base64 数据并返回替换值（见 replacementValue）和是否替换
+// 保存失败时按 --on-error 处理，只有 fail 会返回错误
+func extractBlob(base64Data, mimeType, outputDir string, src blobSource) (interface{}, bool, error) {
+	blob, err := saveBase64Image(base64Data, mimeType, outputDir, src)
+	if err != nil {
+		return handleExtractError(src, err)
+	}
+	return replacementValue(blob), true, nil
+}
+
+// handleExtractError 按 --on-error 处理提取失败
+// fail 返回错误；skip 返回 removeField；keep 返回不替换
+func handleExtractError(src blobSource, err error) (interface{}, bool, error) {
+	failedCount++
+	if exitCodeFor(err) == exitIOError {
+		ioFailedCount++
+	}
+	err = fmt.Errorf("failed to extract data at %s: %w", src.location(), err)
+	if errorPolicy == onErrorFail {
+		return nil, false, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	if errorPolicy == onErrorSkip {
+		return removeField, true, nil
+	}
+	return nil, false, nil
+}
+
+// location 返回数据位置的描述，用于错误信息
+func (src blobSource) location() string {
+	if src.IsText {
+		return fmt.Sprintf("offset %d", src.Offset)
+	}
+	if src.Pointer == "" {
+		return "document root"
+	}
+	return src.Pointer
+}
+
+// trackCreatedFile 记录本次运行新建的文件
+func trackCreatedFile(path string) {
+	createdFiles = append(createdFiles, path)
+}
+
+// trackCreatedDirs 记录创建 dir 时需要新建的目录（在 MkdirAll 之前调用）
+func trackCreatedDirs(dir string) {
+	var missing []string
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	// 从外到内记录，回滚时倒序删除
+	for i := len(missing) - 1; i >= 0; i-- {
+		createdDirs = append(createdDirs, missing[i])
+	}
+}
+
+// rollbackExtraction 删除本次运行新建的文件和目录（--transactional）
+// 内容相同而被复用的已有文件不会被删除
+func rollbackExtraction() {
+	for _, path := range createdFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", path, err)
+		}
+	}
+	for i := len(createdDirs) - 1; i >= 0; i-- {
+		// 只删除空目录
+		os.Remove(createdDirs[i])
+	}
+	if len(createdFiles) > 0 {
+		fmt.Fprintf(os.Stderr, "Rolled back %d extracted file(s)\n", len(createdFiles))
+	}
+	createdFiles = nil
+	createdDirs = nil
+}
+
+// exitCodeFor 根据错误类型返回退出码：输入无效为 1，其他（读写失败）为 3
+func exitCodeFor(err error) int {
+	var corrupt base64.CorruptInputError
+	var syntax *json.SyntaxError
+	switch {
+	case errors.Is(err, errInvalidJSON), errors.As(err, &corrupt), errors.As(err, &syntax),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return exitInvalidInput
+	}
+	return exitIOError
+}
+
+// abortExtraction 输出错误信息并以对应的退出码退出；--transactional 时先删除本次写入的文件
+func abortExtraction(context string, err error) {
+	fmt.Fprintf(os.Stderr, "Error %s: %v\n", context, err)
+	if transactional {
+		rollbackExtraction()
+	}
+	os.Exit(exitCodeFor(err))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxEscapeLayers 最多处理的转义层数（JSON 被反复序列化为字符串时每一层都会再转义一次）
+const maxEscapeLayers = 4
+
+// escapedBase64Pattern 匹配 JSON 转义后的 base64 数据：/ 可以转义为 \/，
+// 各行之间用转义的 \n 或 \r\n 分隔（只有没有 = 填充的行之后才可以继续）
+const escapedBase64Pattern = `(?:[A-Za-z0-9+_-]|\\*/)+(?:(?:\\+r)?\\+n(?:[A-Za-z0-9+_-]|\\*/)+)*=*`
+
+// escapedDataURLPattern 匹配日志中 JSON 转义或反斜杠转义的 Data URL：
+// 媒体类型中的 / 被转义（data:image\/png;base64,...），或 base64 数据被转义的 \n 分隔
+const escapedDataURLPattern = dataURLStart + `(?:` +
+	`[\w.+-]+\\+/[\w.+-]+(?:;[\w.+-]+=[^;,\s"'\\]*)*(?:(?i:;base64),` + escapedBase64Pattern + `|,[^\s"'<>()\\]+)` +
+	`|` + dataURLHeaderPattern + `(?i:;base64),[A-Za-z0-9+/_-]+(?:(?:\\+r)?\\+n[A-Za-z0-9+/_-]+)+=*)`
+
+// textEscaping 文本中数据的转义方式，每层记录是否转义了 /（从外到内）
+type textEscaping []bool
+
+// unescapeText 去掉文本的 JSON 或反斜杠转义（\/、\n、\"、\\、\uXXXX），直到不再包含反斜杠，
+// 返回转义前的文本和转义方式
+func unescapeText(s string) (string, textEscaping) {
+	var esc textEscaping
+	for len(esc) < maxEscapeLayers && strings.ContainsRune(s, '\\') {
+		var slash bool
+		s, slash = unescapeLayer(s)
+		esc = append(esc, slash)
+	}
+	return s, esc
+}
+
+// unescapeLayer 去掉一层转义，返回结果和这一层是否转义了 /
+// 无法识别的转义去掉反斜杠，结尾单独的反斜杠保留
+func unescapeLayer(s string) (string, bool) {
+	var b strings.Builder
+	slash := false
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/':
+			slash = true
+			b.WriteByte('/')
+		case 'u':
+			if i+5 > len(s) {
+				b.WriteString(`\u`)
+				break
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				b.WriteString(`\u`)
+				break
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), slash
+}
+
+// escape 按同样的方式转义替换的内容，使替换后的日志行仍然是合法的 JSON
+func (e textEscaping) escape(s string) string {
+	for i := len(e) - 1; i >= 0; i-- {
+		var b strings.Builder
+		for _, r := range s {
+			switch r {
+			case '\\', '"':
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			case '/':
+				if e[i] {
+					b.WriteByte('\\')
+				}
+				b.WriteRune(r)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		s = b.String()
+	}
+	return s
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// urlAttributes 值为单个 URL 的属性（<img src>、<source src>、<link href>、<video poster>、<object data> 等）
+var urlAttributes = map[string]bool{
+	"src":        true,
+	"href":       true,
+	"xlink:href": true,
+	"poster":     true,
+	"data":       true,
+	"background": true,
+}
+
+// srcsetAttributes 值为候选图片列表的属性（<img srcset>、<source srcset>、<link imagesrcset>）
+var srcsetAttributes = map[string]bool{
+	"srcset":      true,
+	"imagesrcset": true,
+}
+
+// rawTextElements 内容不是标签的元素，扫描时跳过其内容（<style> 的内容按 CSS 处理）
+var rawTextElements = map[string]bool{
+	"script":   true,
+	"textarea": true,
+	"title":    true,
+	"xmp":      true,
+}
+
+// markupResolver 处理 HTML/CSS 中的一个 URL，offset 是该 URL 在文档中的字节偏移
+// 返回新的 URL（字符串）和是否改写，返回 removeField 时删除该引用
+type markupResolver func(ref string, offset int) (interface{}, bool, error)
+
+// markupRewriter 保留原始字节的 HTML/CSS 改写器，只替换图片引用所在的属性值或 url()
+type markupRewriter struct {
+	data    []byte
+	edits   []byteEdit
+	resolve markupResolver
+}
+
+// htmlAttribute 标签中的一个属性
+type htmlAttribute struct {
+	name       string
+	value      string // 解码 HTML 实体后的值
+	start      int    // 属性之前空白的起始位置（删除属性时从这里开始删除）
+	valueStart int    // 属性值（包括引号）的字节范围
+	valueEnd   int
+	quote      byte // 引号字符，没有引号时为 0
+}
+
+// runMarkup 以 HTML 或 CSS 处理输入：把图片引用改写为提取出的文件，其他字节保持不变
+func runMarkup(data []byte, format, outputDir string, pretty bool) {
+	if pretty {
+		fmt.Fprintf(os.Stderr, "Warning: --pretty flag only applies to JSON input, ignoring\n")
+	}
+	if replaceMode == replacePlaceholder {
+		fmt.Fprintf(os.Stderr, "Error: --replace-with placeholder is not supported for HTML/CSS input\n")
+		os.Exit(exitInvalidInput)
+	}
+	output, err := rewriteMarkup(data, format, extractMarkupURL(outputDir, textSource))
+	if err != nil {
+		abortExtraction("processing images", err)
+	}
+	os.Stdout.Write(output)
+}
+
+// rewriteMarkup 扫描 HTML 或 CSS 文档，用 resolve 改写其中的图片引用，返回新的文档
+func rewriteMarkup(data []byte, format string, resolve markupResolver) ([]byte, error) {
+	m := &markupRewriter{data: data, resolve: resolve}
+	var err error
+	if format == inputCSS {
+		err = m.rewriteStyleBlock(0, len(data))
+	} else {
+		err = m.rewriteHTML()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return applyByteEdits(data, m.edits), nil
+}
+
+// extractMarkupURL 返回提取 Data URL 的 markupResolver：保存数据并返回指向文件的 URL
+// source 根据 URL 在文档中的字节偏移返回数据的位置
+func extractMarkupURL(outputDir string, source func(offset int) blobSource) markupResolver {
+	return func(ref string, offset int) (interface{}, bool, error) {
+		u, ok := parseDataURL(strings.TrimSpace(ref))
+		if !ok || !isExtractableMimeType(u.MimeType) {
+			return nil, false, nil
+		}
+		src := source(offset)
+		blob, err := saveDataURL(u, outputDir, src)
+		if err != nil {
+			return handleExtractError(src, err)
+		}
+		if replaceMode == replaceRemove {
+			return removeField, true, nil
+		}
+		return blobURL(blob), true, nil
+	}
+}
+
+// inlineMarkupURL 返回还原文件引用的 markupResolver：把提取出的文件重新编码为 Data URL
+func inlineMarkupURL(outputDir string) markupResolver {
+	return func(ref string, offset int) (interface{}, bool, error) {
+		ref = strings.TrimSpace(ref)
+		if unescaped, err := url.PathUnescape(ref); err == nil {
+			ref = unescaped
+		}
+		path, found := resolveImageReference(ref, outputDir)
+		if !found {
+			return nil, false, nil
+		}
+		dataURL, err := readFileAsDataURL(path)
+		if err != nil {
+			return nil, false, err
+		}
+		return dataURL, true, nil
+	}
+}
+
+// blobURL 返回在 HTML/CSS 中引用已保存文件的 URL（路径中的特殊字符使用百分号编码）
+func blobURL(blob savedBlob) string {
+	link := blobLink(blob)
+	switch replaceMode {
+	case replaceFileURI, replaceURL:
+		return link
+	}
+	return (&url.URL{Path: filepath.ToSlash(link)}).EscapedPath()
+}
+
+// rewriteHTML 扫描 HTML 文档中的标签，跳过注释、<!DOCTYPE>、CDATA 和 <script> 等元素的内容
+func (m *markupRewriter) rewriteHTML() error {
+	data := m.data
+	for i := 0; i < len(data); {
+		lt := bytes.IndexByte(data[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		rest := data[i:]
+
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			i = indexAfter(data, i+4, "-->")
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			i = indexAfter(data, i+9, "]]>")
+		case len(rest) > 1 && (rest[1] == '!' || rest[1] == '?'):
+			// <!DOCTYPE html>、<?xml ...?>
+			i = indexAfter(data, i+2, ">")
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			name, end, err := m.scanTag(i)
+			if err != nil {
+				return err
+			}
+			i = end
+
+			switch {
+			case name == "style":
+				close := indexCloseTag(data, i, name)
+				if err := m.rewriteStyleBlock(i, close); err != nil {
+					return err
+				}
+				i = close
+			case rawTextElements[name]:
+				i = indexCloseTag(data, i, name)
+			}
+		default:
+			// 结束标签和单独的 <
+			i++
+		}
+	}
+	return nil
+}
+
+// scanTag 读取从 start 开始的开始标签（start 处为 <），处理其中的属性，返回小写的标签名和标签结束的位置
+func (m *markupRewriter) scanTag(start int) (string, int, error) {
+	data := m.data
+	i := start + 1
+	for i < len(data) && !isHTMLSpace(data[i]) && data[i] != '/' && data[i] != '>' {
+		i++
+	}
+	name := strings.ToLower(string(data[start+1 : i]))
+
+	for i < len(data) {
+		attrStart := i
+		for i < len(data) && isHTMLSpace(data[i]) {
+			i++
+		}
+		if i >= len(data) {
+			break
+		}
+		if data[i] == '>' {
+			return name, i + 1, nil
+		}
+		if data[i] == '/' {
+			i++
+			continue
+		}
+
+		// 属性名
+		nameStart := i
+		for i < len(data) && !isHTMLSpace(data[i]) && data[i] != '=' && data[i] != '>' && data[i] != '/' {
+			i++
+		}
+		attr := htmlAttribute{name: strings.ToLower(string(data[nameStart:i])), start: attrStart}
+
+		// 属性值（可以没有）
+		j := i
+		for j < len(data) && isHTMLSpace(data[j]) {
+			j++
+		}
+		if j >= len(data) || data[j] != '=' {
+			continue
+		}
+		j++
+		for j < len(data) && isHTMLSpace(data[j]) {
+			j++
+		}
+		attr.valueStart = j
+		if j < len(data) && (data[j] == '"' || data[j] == '\'') {
+			attr.quote = data[j]
+			end := bytes.IndexByte(data[j+1:], attr.quote)
+			if end < 0 {
+				// 没有结束引号，属性值一直到文档结尾
+				return name, len(data), nil
+			}
+			attr.value = html.UnescapeString(string(data[j+1 : j+1+end]))
+			i = j + 1 + end + 1
+		} else {
+			for j < len(data) && !isHTMLSpace(data[j]) && data[j] != '>' {
+				j++
+			}
+			attr.value = html.UnescapeString(string(data[attr.valueStart:j]))
+			i = j
+		}
+		attr.valueEnd = i
+
+		if err := m.rewriteAttribute(attr); err != nil {
+			return "", 0, err
+		}
+	}
+	return name, len(data), nil
+}
+
+// rewriteAttribute 改写属性中的图片引用
+func (m *markupRewriter) rewriteAttribute(attr htmlAttribute) error {
+	var value string
+	var changed bool
+	var err error
+
+	switch {
+	case urlAttributes[attr.name]:
+		var newValue interface{}
+		newValue, changed, err = m.resolve(attr.value, attr.valueStart)
+		if err != nil || !changed {
+			return err
+		}
+		if isRemoval(newValue) {
+			// 删除整个属性
+			m.edits = append(m.edits, byteEdit{start: int64(attr.start), end: int64(attr.valueEnd)})
+			return nil
+		}
+		value = newValue.(string)
+
+	case srcsetAttributes[attr.name]:
+		value, changed, err = m.rewriteSrcset(attr.value, attr.valueStart)
+		if changed && value == "" {
+			// 所有候选图片都被删除时删除整个属性
+			m.edits = append(m.edits, byteEdit{start: int64(attr.start), end: int64(attr.valueEnd)})
+			return err
+		}
+
+	case attr.name == "style":
+		// CSS 中的 url() 使用与属性不同的引号，避免转义
+		quote := byte('"')
+		if attr.quote == '"' {
+			quote = '\''
+		}
+		value, changed, err = m.rewriteCSS(attr.value, attr.valueStart, quote)
+	}
+
+	if err != nil || !changed {
+		return err
+	}
+	m.edits = append(m.edits, byteEdit{
+		start:       int64(attr.valueStart),
+		end:         int64(attr.valueEnd),
+		replacement: []byte(quoteAttribute(value, attr.quote)),
+	})
+	return nil
+}
+
+// srcsetCandidate srcset 中的一个候选图片：URL 和描述符（如 2x、480w）在属性值中的范围
+type srcsetCandidate struct {
+	start, urlEnd, end int
+}
+
+// parseSrcset 按 HTML 规范解析 srcset：URL 是连续的非空白字符（Data URL 中的逗号不是分隔符），
+// 以逗号结尾的 URL 去掉逗号，之后的描述符一直到括号外的逗号
+func parseSrcset(value string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+	i := 0
+	for {
+		for i < len(value) && (isHTMLSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		if i >= len(value) {
+			return candidates
+		}
+
+		c := srcsetCandidate{start: i}
+		for i < len(value) && !isHTMLSpace(value[i]) {
+			i++
+		}
+		c.urlEnd = i
+		if value[i-1] == ',' {
+			for c.urlEnd > c.start && value[c.urlEnd-1] == ',' {
+				c.urlEnd--
+			}
+			c.end = c.urlEnd
+			candidates = append(candidates, c)
+			continue
+		}
+
+		depth := 0
+		for i < len(value) && (value[i] != ',' || depth > 0) {
+			switch value[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			i++
+		}
+		c.end = len(strings.TrimRight(value[:i], " \t\r\n\f"))
+		candidates = append(candidates, c)
+	}
+}
+
+// rewriteSrcset 改写 srcset 中的每个候选图片，删除的候选图片连同描述符一起删除
+func (m *markupRewriter) rewriteSrcset(value string, offset int) (string, bool, error) {
+	var b strings.Builder
+	var kept []string
+	last := 0
+	changed, removed := false, false
+	for _, c := range parseSrcset(value) {
+		newValue, replaced, err := m.resolve(value[c.start:c.urlEnd], offset+c.start)
+		if err != nil {
+			return "", false, err
+		}
+
+		candidate := value[c.start:c.end]
+		if replaced {
+			changed = true
+			if isRemoval(newValue) {
+				removed = true
+				continue
+			}
+			// srcset 中的 URL 不能以逗号结尾（文件路径中的空白已经过百分号编码）
+			link := newValue.(string)
+			if strings.HasSuffix(link, ",") {
+				link = strings.TrimSuffix(link, ",") + "%2C"
+			}
+			b.WriteString(value[last:c.start])
+			b.WriteString(link)
+			last = c.urlEnd
+			candidate = link + value[c.urlEnd:c.end]
+		}
+		kept = append(kept, candidate)
+	}
+	b.WriteString(value[last:])
+
+	if removed {
+		// 有删除时重新拼接剩余的候选图片
+		return strings.Join(kept, ", "), true, nil
+	}
+	return b.String(), changed, nil
+}
+
+// rewriteStyleBlock 改写 <style> 元素或 CSS 文件中 [start, end) 范围内的 url()
+func (m *markupRewriter) rewriteStyleBlock(start, end int) error {
+	css, changed, err := m.rewriteCSS(string(m.data[start:end]), start, '"')
+	if err != nil || !changed {
+		return err
+	}
+	m.edits = append(m.edits, byteEdit{start: int64(start), end: int64(end), replacement: []byte(css)})
+	return nil
+}
+
+// rewriteCSS 改写 CSS 中的 url()，跳过注释和 url() 之外的字符串，offset 是 css 在文档中的字节偏移，
+// quote 是新 URL 使用的引号；删除的引用替换为 none
+func (m *markupRewriter) rewriteCSS(css string, offset int, quote byte) (string, bool, error) {
+	var b strings.Builder
+	last := 0
+	changed := false
+	for i := 0; i < len(css); {
+		switch {
+		case strings.HasPrefix(css[i:], "/*"):
+			i = indexAfter([]byte(css), i+2, "*/")
+		case css[i] == '"' || css[i] == '\'':
+			_, i = readCSSString(css, i)
+		case len(css)-i >= 4 && strings.EqualFold(css[i:i+4], "url(") && (i == 0 || !isCSSNameChar(css[i-1])):
+			ref, end, ok := parseCSSURL(css, i+4)
+			if !ok {
+				i += 4
+				continue
+			}
+			newValue, replaced, err := m.resolve(ref, offset+i)
+			if err != nil {
+				return "", false, err
+			}
+			if replaced {
+				b.WriteString(css[last:i])
+				if isRemoval(newValue) {
+					b.WriteString("none")
+				} else {
+					b.WriteString("url(" + quoteCSSString(newValue.(string), quote) + ")")
+				}
+				last = end
+				changed = true
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	b.WriteString(css[last:])
+	return b.String(), changed, nil
+}
+
+// parseCSSURL 解析 url( 之后的内容（start 位于左括号之后），返回解码后的 URL 和右括号之后的位置
+func parseCSSURL(css string, start int) (string, int, bool) {
+	i := start
+	for i < len(css) && isHTMLSpace(css[i]) {
+		i++
+	}
+	if i >= len(css) {
+		return "", 0, false
+	}
+
+	var ref string
+	if css[i] == '"' || css[i] == '\'' {
+		ref, i = readCSSString(css, i)
+	} else {
+		var b strings.Builder
+		for i < len(css) && css[i] != ')' && !isHTMLSpace(css[i]) {
+			if css[i] == '\\' && i+1 < len(css) {
+				n := unescapeCSS(&b, css, i+1)
+				i = n
+				continue
+			}
+			b.WriteByte(css[i])
+			i++
+		}
+		ref = b.String()
+	}
+
+	for i < len(css) && isHTMLSpace(css[i]) {
+		i++
+	}
+	if i >= len(css) || css[i] != ')' {
+		return "", 0, false
+	}
+	return ref, i + 1, true
+}
+
+// readCSSString 读取从 start 开始的 CSS 字符串（start 处为引号），返回解码后的内容和结束引号之后的位置
+func readCSSString(css string, start int) (string, int) {
+	quote := css[start]
+	var b strings.Builder
+	i := start + 1
+	for i < len(css) && css[i] != quote {
+		if css[i] == '\\' && i+1 < len(css) {
+			i = unescapeCSS(&b, css, i+1)
+			continue
+		}
+		b.WriteByte(css[i])
+		i++
+	}
+	if i < len(css) {
+		i++
+	}
+	return b.String(), i
+}
+
+// unescapeCSS 解码 CSS 转义（i 位于反斜杠之后）：反斜杠加换行为续行，反斜杠加十六进制数字为 Unicode 码点，
+// 其他字符按原样保留，返回转义之后的位置
+func unescapeCSS(b *strings.Builder, css string, i int) int {
+	switch {
+	case css[i] == '\n':
+		return i + 1
+	case css[i] == '\r':
+		if i+1 < len(css) && css[i+1] == '\n' {
+			return i + 2
+		}
+		return i + 1
+	case isHexDigit(css[i]):
+		code := 0
+		j := i
+		for j < len(css) && j-i < 6 && isHexDigit(css[j]) {
+			code = code<<4 | int(unhex(css[j]))
+			j++
+		}
+		if j < len(css) && isHTMLSpace(css[j]) {
+			j++
+		}
+		b.WriteRune(rune(code))
+		return j
+	}
+	b.WriteByte(css[i])
+	return i + 1
+}
+
+// quoteCSSString 把字符串编码为带引号的 CSS 字符串
+func quoteCSSString(s string, quote byte) string {
+	q := string(quote)
+	s = strings.NewReplacer(`\`, `\\`, q, `\`+q, "\n", `\a `).Replace(s)
+	return q + s + q
+}
+
+// quoteAttribute 把值编码为带引号的 HTML 属性值，保留原来的引号（没有引号时使用双引号）
+func quoteAttribute(value string, quote byte) string {
+	if quote == 0 {
+		quote = '"'
+	}
+	q := string(quote)
+	entity := "&quot;"
+	if quote == '\'' {
+		entity = "&#39;"
+	}
+	return q + strings.NewReplacer("&", "&amp;", q, entity).Replace(value) + q
+}
+
+// indexAfter 返回 data 中从 start 开始第一次出现 sep 之后的位置，找不到时返回 len(data)
+func indexAfter(data []byte, start int, sep string) int {
+	if i := bytes.Index(data[start:], []byte(sep)); i >= 0 {
+		return start + i + len(sep)
+	}
+	return len(data)
+}
+
+// indexCloseTag 返回从 start 开始第一个结束标签 </name 的位置（不区分大小写），找不到时返回 len(data)
+func indexCloseTag(data []byte, start int, name string) int {
+	for i := start; i < len(data); {
+		lt := bytes.Index(data[i:], []byte("</"))
+		if lt < 0 {
+			break
+		}
+		i += lt
+		end := i + 2 + len(name)
+		if end <= len(data) && strings.EqualFold(string(data[i+2:end]), name) &&
+			(end == len(data) || isHTMLSpace(data[end]) || data[end] == '/' || data[end] == '>') {
+			return i
+		}
+		i += 2
+	}
+	return len(data)
+}
+
+// isHTMLSpace 判断是否是 HTML 中的空白字符
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isASCIILetter 判断是否是 ASCII 字母
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isCSSNameChar 判断是否是 CSS 标识符中的字符（用于确认 url( 不是其他函数名的一部分）
+func isCSSNameChar(c byte) bool {
+	return isASCIILetter(c) || (c >= '0' && c <= '9') || c == '-' || c == '_'
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inlineJSON 还原一个或多个 JSON 文档（JSON Lines）中的文件引用，只替换引用所在的字节范围，字段顺序、数字、转义和缩进保持不变
+// 结构化格式 {mime_type, data} 和 manifest 中记录为裸 base64 的数据还原为纯 base64，其他字符串还原为 Data URL
+// pointer 是文档的 JSON Pointer（嵌入在字符串中的文档为该字符串的位置）
+func inlineJSON(data []byte, outputDir, pointer string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	rw := &jsonRewriter{data: data, dec: dec, outputDir: outputDir}
+
+	for {
+		t, err := rw.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+		if err := rw.inlineChild(t, pointer); err != nil {
+			return nil, err
+		}
+	}
+	return applyByteEdits(data, rw.edits), nil
+}
+
+// blobObjectFields --replace-with object 生成的对象的字段（见 blobObject）
+var blobObjectFields = map[string]bool{"path": true, "mime_type": true, "bytes": true, "sha256": true, "width": true, "height": true}
+
+// objectRef 一个 --replace-with object 生成的对象：对象的字节范围和引用的文件
+type objectRef struct {
+	start, end int64
+	path       string
+	pointer    string
+}
+
+// inlineValue 还原一个 JSON 值中的文件引用，t 是该值的第一个 token，pointer 是该值的 JSON Pointer
+// 值是 --replace-with object 生成的对象时返回该对象，由调用方决定还原为 Data URL 还是纯 base64
+func (rw *jsonRewriter) inlineValue(t spanToken, pointer string) (*objectRef, error) {
+	switch v := t.tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return rw.inlineObject(t.end-1, pointer)
+		case '[':
+			return nil, rw.inlineArray(pointer)
+		}
+		return nil, fmt.Errorf("%w: unexpected delimiter %q", errInvalidJSON, v)
+
+	case string:
+		inlined, err := inlineStringValue(v, rw.outputDir, pointer)
+		if err != nil || inlined == v {
+			return nil, err
+		}
+		rw.replaceValue(t, inlined)
+	}
+	return nil, nil
+}
+
+// inlineChild 还原一个值，值是 --replace-with object 生成的对象时替换为 Data URL（裸 base64 数据为纯 base64）
+func (rw *jsonRewriter) inlineChild(t spanToken, pointer string) error {
+	ref, err := rw.inlineValue(t, pointer)
+	if err != nil || ref == nil {
+		return err
+	}
+	return rw.inlineObjectRef(ref, false)
+}
+
+// inlineObjectRef 把 --replace-with object 生成的对象替换为 Data URL（plain 为 true 时为纯 base64）
+func (rw *jsonRewriter) inlineObjectRef(ref *objectRef, plain bool) error {
+	var encoded string
+	var err error
+	if plain || isBareReference(ref.pointer, ref.path) {
+		encoded, err = readFileAsBase64(ref.path)
+	} else {
+		encoded, err = readFileAsDataURL(ref.path)
+	}
+	if err != nil {
+		return err
+	}
+	rw.edits = append(rw.edits, byteEdit{start: ref.start, end: ref.end, replacement: encodeJSONValue(encoded)})
+	return nil
+}
+
+// blobObjectPath 判断对象是否是 --replace-with object 生成的对象，返回引用的文件路径
+func (rw *jsonRewriter) blobObjectPath(start, end int64) (string, bool) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(rw.data[start:end], &obj); err != nil {
+		return "", false
+	}
+	for key := range obj {
+		if !blobObjectFields[key] {
+			return "", false
+		}
+	}
+	ref, _ := obj["path"].(string)
+	_, hasBytes := obj["bytes"].(float64)
+	_, hasSHA256 := obj["sha256"].(string)
+	if !hasBytes || !hasSHA256 {
+		return "", false
+	}
+	return resolveImageReference(ref, rw.outputDir)
+}
+
+// inlineObject 还原一个 JSON 对象中的文件引用（起始的 '{' 已被读取，start 为其位置）
+// 对象本身是 --replace-with object 生成的对象时返回该对象
+func (rw *jsonRewriter) inlineObject(start int64, pointer string) (*objectRef, error) {
+	edits := len(rw.edits)
+	var dataToken *spanToken
+	var dataRef *objectRef
+	hasMimeType := false
+
+	for rw.dec.More() {
+		keyTok, err := rw.next()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+		key, _ := keyTok.tok.(string)
+
+		val, err := rw.next()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		if _, ok := val.tok.(string); ok {
+			if key == "data" && dataToken == nil {
+				// 可能是结构化格式的 data 字段，等对象结束后再根据 mime_type 处理
+				dataToken = &val
+				continue
+			}
+			if isMimeTypeKey(key) {
+				hasMimeType = true
+			}
+		}
+		ref, err := rw.inlineValue(val, appendPointer(pointer, key))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ref != nil && key == "data" && dataRef == nil:
+			dataRef = ref
+		case ref != nil:
+			if err := rw.inlineObjectRef(ref, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 读取结束的 '}'
+	if _, err := rw.next(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+	end := rw.lastOffset
+
+	if path, ok := rw.blobObjectPath(start, end); ok {
+		// 丢弃对字段 path 的还原，由调用方替换整个对象
+		rw.edits = rw.edits[:edits]
+		return &objectRef{start: start, end: end, path: path, pointer: pointer}, nil
+	}
+
+	// 结构化格式 {mime_type, data} 的 data 字段还原为纯 base64
+	if dataRef != nil {
+		return nil, rw.inlineObjectRef(dataRef, hasMimeType)
+	}
+	if dataToken == nil {
+		return nil, nil
+	}
+	if !hasMimeType {
+		return nil, rw.inlineChild(*dataToken, appendPointer(pointer, "data"))
+	}
+	path, found := resolveImageReference(dataToken.tok.(string), rw.outputDir)
+	if !found {
+		return nil, nil
+	}
+	encoded, err := readFileAsBase64(path)
+	if err != nil {
+		return nil, err
+	}
+	rw.replaceValue(*dataToken, encoded)
+	return nil, nil
+}
+
+// inlineArray 还原一个 JSON 数组中的文件引用（起始的 '[' 已被读取）
+func (rw *jsonRewriter) inlineArray(pointer string) error {
+	for i := 0; rw.dec.More(); i++ {
+		t, err := rw.next()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+		if err := rw.inlineChild(t, appendPointerIndex(pointer, i)); err != nil {
+			return err
+		}
+	}
+
+	// 读取结束的 ']'
+	if _, err := rw.next(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+	return nil
+}
+
+// inlineStringValue 还原单个字符串值，pointer 是该字符串的 JSON Pointer
+// 整个字符串是文件引用时还原为 Data URL（manifest 中记录为裸 base64 时还原为纯 base64）；
+// 是嵌入的 JSON 文档时（--nested）还原内层文档；否则还原其中的 Markdown 图片引用
+func inlineStringValue(value, outputDir, pointer string) (string, error) {
+	if path, found := resolveImageReference(value, outputDir); found {
+		if isBareReference(pointer, path) {
+			return readFileAsBase64(path)
+		}
+		return readFileAsDataURL(path)
+	}
+	if nested, replaced, err := inlineNestedJSON(value, outputDir, pointer); err != nil || replaced {
+		return nested, err
+	}
+	return inlineTextContent(value, outputDir)
+}
+
+// inlineTextContent 处理纯文本内容，把 Markdown 图片（行内图片、引用式图片的定义）和内嵌 HTML 标签中的文件引用还原为 Data URL
+// 与 bundle 模式使用同样的匹配规则（bundleMarkdownRe），只替换图片的目标，alt、标题和周围的文字保持不变
+func inlineTextContent(text, outputDir string) (string, error) {
+	var result strings.Builder
+	last := 0
+	for _, loc := range bundleMarkdownRe.FindAllStringSubmatchIndex(text, -1) {
+		var start, end int
+		var replacement string
+		switch {
+		case loc[2] >= 0 || loc[8] >= 0:
+			start, end = firstGroup(loc, 2, 3, 5, 6)
+			path, found := resolveImageReference(text[start:end], outputDir)
+			if !found {
+				continue
+			}
+			dataURL, err := readFileAsDataURL(path)
+			if err != nil {
+				return "", err
+			}
+			replacement = dataURL
+		default:
+			start, end = loc[14], loc[15]
+			m := &markupRewriter{data: []byte(text[start:end]), resolve: inlineMarkupURL(outputDir)}
+			if _, _, err := m.scanTag(0); err != nil {
+				return "", err
+			}
+			if len(m.edits) == 0 {
+				continue
+			}
+			replacement = string(applyByteEdits(m.data, m.edits))
+		}
+
+		result.WriteString(text[last:start])
+		result.WriteString(replacement)
+		last = end
+	}
+	result.WriteString(text[last:])
+	return result.String(), nil
+}
+
+// resolveImageReference 判断字符串是否是提取出的文件引用，返回实际文件路径
+// 接受各种 --replace-with 生成的引用：相对路径 decoded/xxx.png（输出目录名 + 文件名）、
+// 输出目录中的绝对路径、file:// URI 和以 --base-url 开头的 URL；扩展名必须在 MIME 类型表中并且文件确实存在
+func resolveImageReference(ref, outputDir string) (string, bool) {
+	if ref == "" || strings.HasPrefix(ref, "data:") || mimeTypeForExtension(filepath.Ext(ref)) == "" {
+		return "", false
+	}
+
+	decodedDir, err := resolveDecodedDir(outputDir)
+	if err != nil {
+		return "", false
+	}
+
+	// 引用的文件在输出目录中的相对路径
+	var name string
+	base := strings.TrimSuffix(baseURL, "/") + "/"
+	switch {
+	case baseURL != "" && strings.HasPrefix(ref, base):
+		unescaped, err := url.PathUnescape(strings.TrimPrefix(ref, base))
+		if err != nil {
+			return "", false
+		}
+		name = filepath.Clean(filepath.FromSlash(unescaped))
+	case strings.HasPrefix(ref, "file://"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", false
+		}
+		// Windows 路径: file:///C:/...
+		p := u.Path
+		if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+			p = p[1:]
+		}
+		name = relativeToDir(decodedDir, filepath.FromSlash(p))
+	case isURL(ref):
+		return "", false
+	case filepath.IsAbs(ref):
+		name = relativeToDir(decodedDir, ref)
+	default:
+		prefix := filepath.Base(decodedDir) + string(filepath.Separator)
+		cleaned := filepath.Clean(filepath.FromSlash(ref))
+		if !strings.HasPrefix(cleaned, prefix) {
+			return "", false
+		}
+		name = strings.TrimPrefix(cleaned, prefix)
+	}
+	if name == "" || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	path := filepath.Join(decodedDir, name)
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		return path, true
+	}
+	return "", false
+}
+
+// relativeToDir 返回绝对路径 path 相对于目录 dir 的路径，不在目录中时返回空字符串
+func relativeToDir(dir, path string) string {
+	rel, err := filepath.Rel(absolutePath(dir), filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return rel
+}
+
+// readFileAsBase64 读取文件并编码为纯 base64
+func readFileAsBase64(path string) (string, error) {
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image file: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(fileData), nil
+}
+
+// readFileAsDataURL 读取文件并编码为 Data URL (data:image/png;base64,...)，MIME 类型由扩展名确定
+func readFileAsDataURL(path string) (string, error) {
+	encoded, err := readFileAsBase64(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimeTypeForExtension(filepath.Ext(path)), encoded), nil
+}
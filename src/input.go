@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 输入格式（--input-format）
+const (
+	inputAuto = "auto" // 按扩展名和内容识别，默认
+	inputJSON = "json" // JSON（不是合法的 JSON 时作为纯文本处理）
+	inputText = "text" // 纯文本 / Markdown
+	inputHTML = "html" // HTML，只改写标签属性和 <style> 中的图片引用
+	inputCSS  = "css"  // CSS，只改写 url() 中的图片引用
+	inputMIME = "mime" // MIME 消息（.eml 邮件、.mhtml 网页存档），提取 base64 编码的部分
+	inputYAML = "yaml" // YAML（Kubernetes 清单、Helm values 等），注释和键的顺序保持不变
+	inputTOML = "toml" // TOML 配置文件，注释和键的顺序保持不变
+	inputXML  = "xml"  // XML（SVG、Apple plist、SOAP 等），只改写提取出的数据
+)
+
+var (
+	inputFormat = inputAuto // 由 --input-format 设置
+	inputName   string      // 输入文件名（从标准输入读取时为空），用于按扩展名识别输入格式
+)
+
+// inputExtensions 扩展名对应的输入格式
+var inputExtensions = map[string]string{
+	".html":  inputHTML,
+	".htm":   inputHTML,
+	".xhtml": inputHTML,
+	".css":   inputCSS,
+	".eml":   inputMIME,
+	".mht":   inputMIME,
+	".mhtml": inputMIME,
+	".yaml":  inputYAML,
+	".yml":   inputYAML,
+	".toml":  inputTOML,
+	".xml":   inputXML,
+	".svg":   inputXML,
+	".plist": inputXML,
+}
+
+// mimeHeaderRe 匹配 MIME 消息开头的头部：第一行是头部字段，头部中有 MIME-Version 字段
+var mimeHeaderRe = regexp.MustCompile(`^[!-9;-~]+:[^\n]*\n(?:[^\n]+\n)*?(?i:mime-version):`)
+
+// xmlPrefixes 按 XML 处理的内容开头（小写）
+var xmlPrefixes = []string{"<?xml", "<svg", "<plist", "<!doctype svg", "<!doctype plist"}
+
+// validateInputFormat 检查 --input-format 参数
+func validateInputFormat() error {
+	switch inputFormat {
+	case inputAuto, inputJSON, inputText, inputHTML, inputCSS, inputMIME, inputYAML, inputTOML, inputXML:
+		return nil
+	}
+	return fmt.Errorf("invalid --input-format value %q (expected auto, json, text, html, css, mime, yaml, toml or xml)", inputFormat)
+}
+
+// detectInputFormat 返回输入的格式：指定了 --input-format 时直接使用，
+// 否则按文件扩展名识别，再检查内容是否以 <!DOCTYPE html> 或 <html 开头、是否以包含 MIME-Version 的头部开头、
+// 是否以 <?xml、<svg 或 <plist 开头（开头部分有 <html 的 XHTML 按 HTML 处理），都不是时按 JSON 处理
+func detectInputFormat(data []byte) string {
+	if inputFormat != inputAuto {
+		return inputFormat
+	}
+	if format, ok := inputExtensions[strings.ToLower(filepath.Ext(inputName))]; ok {
+		return format
+	}
+
+	head := bytes.TrimLeft(data[:min(len(data), 1024)], " \t\r\n\ufeff")
+	if mimeHeaderRe.Match(head) {
+		return inputMIME
+	}
+	lower := bytes.ToLower(head)
+	if bytes.HasPrefix(lower, []byte("<!doctype html")) || bytes.HasPrefix(lower, []byte("<html")) {
+		return inputHTML
+	}
+	for _, prefix := range xmlPrefixes {
+		if bytes.HasPrefix(lower, []byte(prefix)) {
+			if bytes.Contains(lower, []byte("<html")) {
+				return inputHTML
+			}
+			return inputXML
+		}
+	}
+	return inputJSON
+}
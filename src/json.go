@@ -1,84 +1,79 @@
 package main
 
 import (
-	"fmt"
-	"os"
-	"regexp"
 	"strings"
+	"unicode"
 )
 
-// processTextContent 处理纯文本内容，查找并替换 base64 图片
-func processTextContent(text, outputDir string) string {
-	// 处理 Markdown 格式: ![alt](data:image/png;base64,...)
-	mdRe := regexp.MustCompile(`!\[([^\]]*)\]\(data:(image/[^;]+);base64,([^)]+)\)`)
-	text = mdRe.ReplaceAllStringFunc(text, func(match string) string {
-		matches := mdRe.FindStringSubmatch(match)
-		if len(matches) == 4 {
-			altText := matches[1]
-			mimeType := matches[2]
-			base64Data := matches[3]
-
-			filename, err := saveBase64Image(base64Data, mimeType, outputDir)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to save markdown image: %v\n", err)
-				return match // 保持原样
-			}
-			return fmt.Sprintf("![%s](%s)", altText, filename)
-		}
-		return match
-	})
-
-	// 处理普通 Data URL 格式: data:image/png;base64,...
-	dataURLRe := regexp.MustCompile(`data:(image/[^;]+);base64,([A-Za-z0-9+/=]+)`)
-	text = dataURLRe.ReplaceAllStringFunc(text, func(match string) string {
-		matches := dataURLRe.FindStringSubmatch(match)
-		if len(matches) == 3 {
-			mimeType := matches[1]
-			base64Data := matches[2]
-
-			filename, err := saveBase64Image(base64Data, mimeType, outputDir)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to save data URL image: %v\n", err)
-				return match // 保持原样
-			}
-			return filename
-		}
-		return match
-	})
+// mimeTypePattern 匹配 Data URL 中的 MIME 类型（如 image/png、application/pdf、image/svg+xml）
+const mimeTypePattern = `[\w.+-]+/[\w.+-]+`
+
+// base64TextPattern 匹配文本中的 base64 数据：标准或 URL 安全的字母表，填充可以省略；
+// 换行的数据只有上一行正好是 76 或 64 个字符（MIME、PEM 的换行宽度）并且没有填充时才继续到下一行，
+// 避免把数据之后的文字（如 --- 或 Thanks）当作数据
+const base64TextPattern = `(?:(?:[A-Za-z0-9+/_-]{76}\r?\n)+[A-Za-z0-9+/_-]+=*` +
+	`|(?:[A-Za-z0-9+/_-]{64}\r?\n)+[A-Za-z0-9+/_-]+=*` +
+	`|[A-Za-z0-9+/_-]+=*)`
 
-	return text
+// processTextContent 处理纯文本内容，查找并替换 base64 图片
+// 只有 --on-error fail 时提取失败才会返回错误
+func processTextContent(text, outputDir string) (string, error) {
+	result, _, err := replaceEmbeddedDataURLs(text, outputDir, textSource)
+	return result, err
 }
 
 // processImages 递归处理 JSON 数据，查找并保存 base64 图片
-func processImages(data interface{}, outputDir string) error {
+// pointer 是 data 在整个文档中的 JSON Pointer（根节点为空字符串）
+func processImages(data interface{}, pointer, outputDir string) error {
 	switch v := data.(type) {
 	case map[string]interface{}:
+		siblings := scalarSiblings(v)
+
 		// 检查是否包含图片数据（原格式：mime_type + data 字段）
-		if mimeType, ok := v["mime_type"].(string); ok {
-			if strings.HasPrefix(mimeType, "image/") {
+		if mimeType, ok := structuredMimeType(v); ok {
+			if isExtractableMimeType(mimeType) && pathSelected(appendPointer(pointer, "data")) {
 				if dataStr, ok := v["data"].(string); ok {
 					// 保存图片并替换数据
-					filename, err := saveBase64Image(dataStr, mimeType, outputDir)
+					value, replaced, err := extractBlob(dataStr, mimeType, outputDir, structuredSource(appendPointer(pointer, "data"), siblings))
 					if err != nil {
 						return err
 					}
-					v["data"] = filename
+					if replaced && isRemoval(value) {
+						delete(v, "data")
+					} else if replaced {
+						v["data"] = value
+					}
 				}
 			}
 		}
 
 		// 递归处理所有字段，同时检查 Data URL 格式
-		for key, value := range v {
+		// 按键的顺序遍历（与输出顺序一致），保证计数器和文件名在多次运行之间相同
+		for _, key := range sortedKeys(v) {
+			value := v[key]
+			childPointer := appendPointer(pointer, key)
+
 			// 检查字符串值是否是 Data URL 格式
 			if strValue, ok := value.(string); ok {
-				if filename, replaced := processDataURL(strValue, outputDir); replaced {
-					v[key] = filename
+				if !pathSelected(childPointer) {
+					continue
+				}
+				value, replaced, err := processDataURL(strValue, outputDir, jsonSource(childPointer, siblings))
+				if err != nil {
+					return err
+				}
+				if replaced {
+					if isRemoval(value) {
+						delete(v, key)
+					} else {
+						v[key] = value
+					}
 					continue
 				}
 			}
 
 			// 递归处理嵌套结构
-			if err := processImages(value, outputDir); err != nil {
+			if err := processImages(value, childPointer, outputDir); err != nil {
 				return err
 			}
 		}
@@ -86,16 +81,29 @@ func processImages(data interface{}, outputDir string) error {
 	case []interface{}:
 		// 递归处理数组
 		for i, item := range v {
+			childPointer := appendPointerIndex(pointer, i)
+
 			// 检查数组元素是否是 Data URL 格式的字符串
 			if strValue, ok := item.(string); ok {
-				if filename, replaced := processDataURL(strValue, outputDir); replaced {
-					v[i] = filename
+				if !pathSelected(childPointer) {
+					continue
+				}
+				value, replaced, err := processDataURL(strValue, outputDir, jsonSource(childPointer, nil))
+				if err != nil {
+					return err
+				}
+				if replaced {
+					// 删除时数组元素替换为 null，保持其他元素的下标不变
+					if isRemoval(value) {
+						value = nil
+					}
+					v[i] = value
 					continue
 				}
 			}
 
 			// 递归处理嵌套结构
-			if err := processImages(item, outputDir); err != nil {
+			if err := processImages(item, childPointer, outputDir); err != nil {
 				return err
 			}
 		}
@@ -104,48 +112,37 @@ func processImages(data interface{}, outputDir string) error {
 	return nil
 }
 
-// processDataURL 处理 Data URL 格式的字符串 (data:image/png;base64,...)
-// 同时处理 Markdown 格式: ![image](data:image/png;base64,...)
-// 返回文件名和是否成功处理的标志
-func processDataURL(dataURL, outputDir string) (string, bool) {
-	// 首先检查是否是 Markdown 格式: ![alt](data:image/...;base64,...)
-	mdRe := regexp.MustCompile(`!\[([^\]]*)\]\(data:(image/[^;]+);base64,([^)]+)\)`)
-	mdMatches := mdRe.FindStringSubmatch(dataURL)
-
-	if len(mdMatches) == 4 {
-		altText := mdMatches[1]
-		mimeType := mdMatches[2]
-		base64Data := mdMatches[3]
-
-		// 保存图片
-		filename, err := saveBase64Image(base64Data, mimeType, outputDir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to save markdown image: %v\n", err)
-			return "", false
-		}
-
-		// 返回 Markdown 格式的文件引用
-		return fmt.Sprintf("![%s](%s)", altText, filename), true
+// processDataURL 处理 Data URL 格式的字符串 (data:image/png;base64,...，见 parseDataURL)
+// 同时处理文字中任意位置的 Markdown 图片 ![image](data:image/png;base64,...) 和 Data URL
+// src 是该字符串在原文档中的位置，返回替换值（见 replacementValue）和是否替换
+// 提取失败时按 --on-error 处理，只有 fail 会返回错误
+func processDataURL(dataURL, outputDir string, src blobSource) (interface{}, bool, error) {
+	// 以字符串形式嵌入的 JSON 文档，递归处理其中的数据
+	if nested, replaced, err := processNestedJSON(dataURL, outputDir, src); err != nil || replaced {
+		return nested, replaced, err
 	}
 
-	// 匹配普通 Data URL 格式: data:image/...;base64,...
-	re := regexp.MustCompile(`^data:(image/[^;]+);base64,(.+)$`)
-	matches := re.FindStringSubmatch(dataURL)
+	// 以 data: 开头的字符串整个作为一个 Data URL：base64 数据中的空白和换行由解码器忽略，
+	// 无法解码时按 --on-error 处理，不再在其中查找其他 Data URL
+	u, ok := parseDataURL(strings.TrimRightFunc(dataURL, unicode.IsSpace))
+	if !ok {
+		// 不是 Data URL 时检查是否是裸 base64 图片（--detect-bare）
+		if mimeType, ok := sniffBareBase64(dataURL); ok && isExtractableMimeType(mimeType) {
+			src.Bare = true
+			return extractBlob(dataURL, mimeType, outputDir, src)
+		}
 
-	if len(matches) != 3 {
-		return "", false
+		// 文字中的 Markdown 图片和 Data URL：全部提取，周围的文字保持不变
+		text, replaced, err := replaceEmbeddedDataURLs(dataURL, outputDir, func(int) blobSource { return src })
+		if err != nil || !replaced {
+			return "", false, err
+		}
+		return text, true, nil
 	}
-
-	mimeType := matches[1]
-	base64Data := matches[2]
-
-	// 保存图片
-	filename, err := saveBase64Image(base64Data, mimeType, outputDir)
-	if err != nil {
-		// 如果保存失败，返回原值
-		fmt.Fprintf(os.Stderr, "Warning: failed to save Data URL image: %v\n", err)
-		return "", false
+	if !isExtractableMimeType(u.MimeType) {
+		return "", false, nil
 	}
 
-	return filename, true
+	// 保存数据
+	return extractDataURL(u, outputDir, src)
 }
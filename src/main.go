@@ -11,21 +11,57 @@ import (
 func main() {
 	// 自定义帮助信息
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: b64 [OPTIONS] [FILE|URL]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: b64 [OPTIONS] [FILE|URL]\n")
+		fmt.Fprintf(os.Stderr, "       b64 inline [OPTIONS] [FILE]\n")
+		fmt.Fprintf(os.Stderr, "       b64 scan [OPTIONS] [FILE]\n")
+		fmt.Fprintf(os.Stderr, "       b64 bundle [OPTIONS] [FILE]\n\n")
 		fmt.Fprintf(os.Stderr, "Extract base64 encoded images from text or JSON to decoded/ directory.\n")
 		fmt.Fprintf(os.Stderr, "Or encode image files to base64 format.\n")
 		fmt.Fprintf(os.Stderr, "Or download images from URL and encode to base64 format.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  inline                Re-inline extracted image files back into JSON or Markdown as base64\n")
+		fmt.Fprintf(os.Stderr, "  scan                  List embedded base64 data with location, type and size, without writing files\n")
+		fmt.Fprintf(os.Stderr, "  bundle                Inline local and remote images referenced by Markdown/HTML/CSS as data URLs\n\n")
 		fmt.Fprintf(os.Stderr, "Arguments:\n")
 		fmt.Fprintf(os.Stderr, "  FILE|URL              Input file or URL to process (reads from stdin if not provided)\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		fmt.Fprintf(os.Stderr, "  -f, --format-json     Pretty print JSON output (JSON input only)\n")
 		fmt.Fprintf(os.Stderr, "  -p, --pretty          Pretty print JSON output (JSON input only)\n")
 		fmt.Fprintf(os.Stderr, "  -o, --output DIR      Output directory for encoded image files (image input only)\n")
+		fmt.Fprintf(os.Stderr, "      --input-format F  Input format: auto, json, text, html, css, mime, yaml, toml or xml (default auto: by extension and content)\n")
+		fmt.Fprintf(os.Stderr, "      --stream          Stream JSON input token by token with bounded memory (JSON input only)\n")
+		fmt.Fprintf(os.Stderr, "      --preserve        Only replace extracted base64 strings, keep all other bytes (JSON input only)\n")
+		fmt.Fprintf(os.Stderr, "      --record-naming M Name images of batch records by key/custom_id: dir, prefix or none (default dir)\n")
+		fmt.Fprintf(os.Stderr, "      --types LIST      MIME types to extract, e.g. image/*,audio/*,application/pdf (default image/*)\n")
+		fmt.Fprintf(os.Stderr, "      --detect-bare     Also extract bare base64 strings whose decoded bytes are a known image format\n")
+		fmt.Fprintf(os.Stderr, "      --min-bare-length N Minimum length of bare base64 strings for --detect-bare (default 100)\n")
+		fmt.Fprintf(os.Stderr, "      --nested          Process JSON documents embedded in string values (default true, --nested=false to disable)\n")
+		fmt.Fprintf(os.Stderr, "      --naming MODE     Name extracted files by timestamp or hash (content SHA-256, default timestamp)\n")
+		fmt.Fprintf(os.Stderr, "      --hash-length N   Number of hex digits of SHA-256 used by --naming hash (default 16)\n")
+		fmt.Fprintf(os.Stderr, "      --clock TIME      Fix the time used in file names (RFC 3339 or Unix seconds) for reproducible output\n")
+		fmt.Fprintf(os.Stderr, "      --name-template T Name extracted files by template, e.g. '{date}/{mime_subtype}/{hash8}{ext}'\n")
+		fmt.Fprintf(os.Stderr, "      --replace-with M  Replace extracted data with rel, abs, file-uri, url, object, placeholder or remove (default rel)\n")
+		fmt.Fprintf(os.Stderr, "      --base-url URL    URL prefix of the output directory, used by --replace-with url\n")
+		fmt.Fprintf(os.Stderr, "      --on-error MODE   On extraction errors: fail, skip (drop the data) or keep (keep the data, default)\n")
+		fmt.Fprintf(os.Stderr, "      --transactional   Delete the files written by a failed run\n")
+		fmt.Fprintf(os.Stderr, "      --manifest FILE   Write a JSON manifest describing every extracted blob to FILE\n")
+		fmt.Fprintf(os.Stderr, "                        (inline: read FILE to restore bare base64 fields as bare base64)\n")
+		fmt.Fprintf(os.Stderr, "      --include PATH    Only extract at JSON Pointer / JSONPath PATH (repeatable, JSON input only)\n")
+		fmt.Fprintf(os.Stderr, "      --exclude PATH    Do not extract at JSON Pointer / JSONPath PATH (repeatable, JSON input only)\n")
+		fmt.Fprintf(os.Stderr, "      --format FORMAT   Output format of scan: table or json (default table)\n")
+		fmt.Fprintf(os.Stderr, "      --depth N         Number of path segments used to group scan totals (default 2)\n")
+		fmt.Fprintf(os.Stderr, "      --max-image-size N Keep references to images larger than N (e.g. 500K, 2M) in bundle mode (default no limit)\n")
+		fmt.Fprintf(os.Stderr, "      --skip-remote     Do not download http/https images in bundle mode\n")
 		fmt.Fprintf(os.Stderr, "  -h, --help            Show this help message\n\n")
 		fmt.Fprintf(os.Stderr, "Supported Formats:\n")
 		fmt.Fprintf(os.Stderr, "  - JSON files with base64 images (will be parsed and formatted)\n")
+		fmt.Fprintf(os.Stderr, "  - Structured objects using mime_type, mimeType (Gemini) or media_type (Anthropic)\n")
+		fmt.Fprintf(os.Stderr, "  - JSON Lines and Gemini/OpenAI batch files (processed record by record)\n")
 		fmt.Fprintf(os.Stderr, "  - Plain text with data URLs (e.g., data:image/png;base64,...)\n")
 		fmt.Fprintf(os.Stderr, "  - Markdown with embedded images (e.g., ![alt](data:image/...))\n")
+		fmt.Fprintf(os.Stderr, "  - HTML and CSS: <img src>, srcset, <source>, <link href>, style and <style> url()\n")
+		fmt.Fprintf(os.Stderr, "  - Email (.eml) and web archives (.mhtml): base64 MIME parts, cid: references\n")
+		fmt.Fprintf(os.Stderr, "  - XML: SVG <image href>, plist <data>, xs:base64Binary elements (SVG files need --input-format xml)\n")
 		fmt.Fprintf(os.Stderr, "  - Image files (PNG, JPEG, GIF, WebP, BMP, SVG)\n")
 		fmt.Fprintf(os.Stderr, "  - HTTP/HTTPS URLs pointing to image files\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
@@ -38,24 +74,196 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  b64 http://example.com/pic.jpg # Download and encode image from URL\n")
 		fmt.Fprintf(os.Stderr, "  cat s.json | b64 | jq          # Process from stdin\n")
 		fmt.Fprintf(os.Stderr, "  cat s.json | b64 -f | jq       # Process from stdin with pretty output\n")
+		fmt.Fprintf(os.Stderr, "  b64 --stream huge.json > out   # Process large JSON file with bounded memory\n")
+		fmt.Fprintf(os.Stderr, "  b64 --preserve s.json          # Keep key order, numbers and formatting\n")
+		fmt.Fprintf(os.Stderr, "  b64 batch_output.jsonl         # Process batch file, images in decoded/<key>/\n")
+		fmt.Fprintf(os.Stderr, "  b64 --include '$.candidates[*].content.parts[*].inlineData' s.json\n")
+		fmt.Fprintf(os.Stderr, "  b64 --types 'image/*,audio/*,application/pdf' s.json\n")
+		fmt.Fprintf(os.Stderr, "  b64 --manifest manifest.json s.json\n")
+		fmt.Fprintf(os.Stderr, "  b64 --detect-bare openai_images.json  # Extract b64_json and other bare base64 images\n")
+		fmt.Fprintf(os.Stderr, "  b64 --naming hash s.json        # Reproducible output, identical images stored once\n")
+		fmt.Fprintf(os.Stderr, "  b64 --clock 2025-01-01T00:00:00Z s.json  # Same file names on every run\n")
+		fmt.Fprintf(os.Stderr, "  b64 --name-template '{key}_{index}{ext}' s.json\n")
+		fmt.Fprintf(os.Stderr, "  b64 --replace-with url --base-url https://cdn.example.com/img s.json\n")
+		fmt.Fprintf(os.Stderr, "  b64 page.html > page.out.html  # Extract inline images of a saved web page\n")
+		fmt.Fprintf(os.Stderr, "  b64 message.eml > message.out.eml  # Extract attachments, keep the message\n")
+		fmt.Fprintf(os.Stderr, "  b64 Info.plist > Info.out.plist  # Extract <data> images of a property list\n")
+		fmt.Fprintf(os.Stderr, "  b64 inline out.json            # Restore extracted images as base64\n")
+		fmt.Fprintf(os.Stderr, "  b64 inline --manifest m.json out.json  # Restore --detect-bare fields as bare base64\n")
+		fmt.Fprintf(os.Stderr, "  b64 scan response.json         # Find out what makes a payload large\n")
+		fmt.Fprintf(os.Stderr, "  b64 bundle doc.md > doc.bundled.md  # Self-contained Markdown for chat tools and prompts\n")
+	}
+
+	// 检查子命令（需要在解析参数前移除，以便子命令后面的参数也能被解析）
+	var command string
+	if len(os.Args) > 1 && (os.Args[1] == "inline" || os.Args[1] == "scan" || os.Args[1] == "bundle") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
 	}
 
 	// 定义命令行参数
 	var pretty bool
 	var outputDir string
+	var stream bool
+	var preserve bool
 	flag.BoolVar(&pretty, "pretty", false, "pretty print JSON output")
 	flag.BoolVar(&pretty, "p", false, "pretty print JSON output")
 	flag.BoolVar(&pretty, "format-json", false, "pretty print JSON output")
 	flag.BoolVar(&pretty, "f", false, "pretty print JSON output")
 	flag.StringVar(&outputDir, "output", "", "output directory for encoded image files")
 	flag.StringVar(&outputDir, "o", "", "output directory for encoded image files")
+	flag.StringVar(&inputFormat, "input-format", inputAuto, "input format: auto, json, text, html, css, mime, yaml, toml or xml")
+	flag.BoolVar(&stream, "stream", false, "stream JSON input with bounded memory")
+	flag.BoolVar(&preserve, "preserve", false, "keep all bytes of JSON input except extracted base64 strings")
+	flag.StringVar(&recordNaming, "record-naming", recordNamingDir, "name images of batch records by key/custom_id: dir, prefix or none")
+	var types string
+	flag.StringVar(&types, "types", "image/*", "comma separated MIME types to extract")
+	flag.BoolVar(&detectBare, "detect-bare", false, "also extract bare base64 image strings")
+	flag.IntVar(&minBareLength, "min-bare-length", 100, "minimum length of bare base64 strings for --detect-bare")
+	flag.BoolVar(&processNested, "nested", true, "process JSON documents embedded in string values")
+	flag.StringVar(&namingMode, "naming", namingTimestamp, "name extracted files by timestamp or hash")
+	flag.IntVar(&hashLength, "hash-length", 16, "number of hex digits of SHA-256 used by --naming hash")
+	var clock string
+	flag.StringVar(&clock, "clock", "", "fix the time used in file names (RFC 3339 or Unix seconds)")
+	flag.StringVar(&nameTemplate, "name-template", "", "name extracted files by template")
+	flag.StringVar(&replaceMode, "replace-with", replaceRelative, "replace extracted data with rel, abs, file-uri, url, object, placeholder or remove")
+	flag.StringVar(&baseURL, "base-url", "", "URL prefix of the output directory, used by --replace-with url")
+	flag.StringVar(&errorPolicy, "on-error", onErrorKeep, "on extraction errors: fail, skip or keep")
+	flag.BoolVar(&transactional, "transactional", false, "delete the files written by a failed run")
+	flag.StringVar(&manifestPath, "manifest", "", "write a JSON manifest of extracted blobs to file (inline: read it)")
+	flag.Var(&includePaths, "include", "only extract at JSON Pointer / JSONPath (repeatable)")
+	flag.StringVar(&scanFormat, "format", scanFormatTable, "output format of scan: table or json")
+	flag.IntVar(&scanDepth, "depth", 2, "number of path segments used to group scan totals")
+	flag.Var(&excludePaths, "exclude", "do not extract at JSON Pointer / JSONPath (repeatable)")
+	var maxSize string
+	flag.StringVar(&maxSize, "max-image-size", "", "keep references to larger images in bundle mode (e.g. 500K, 2M)")
+	flag.BoolVar(&skipRemote, "skip-remote", false, "do not download http/https images in bundle mode")
 	flag.Parse()
 
+	allowedTypes = parseTypesList(types)
+	if command == "scan" && !flagPassed("types") {
+		// scan 默认列出所有类型的数据
+		allowedTypes = []string{"*/*"}
+	}
+
+	switch recordNaming {
+	case recordNamingDir, recordNamingPrefix, recordNamingNone:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid --record-naming value %q (expected dir, prefix or none)\n", recordNaming)
+		os.Exit(1)
+	}
+	if namingMode != namingTimestamp && namingMode != namingHash {
+		fmt.Fprintf(os.Stderr, "Error: invalid --naming value %q (expected timestamp or hash)\n", namingMode)
+		os.Exit(1)
+	}
+	if minBareLength < 8 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --min-bare-length value %d (expected at least 8)\n", minBareLength)
+		os.Exit(1)
+	}
+	if hashLength < 8 || hashLength > 64 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --hash-length value %d (expected 8 to 64)\n", hashLength)
+		os.Exit(1)
+	}
+	if clock != "" {
+		t, err := parseClock(clock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fixedClock = &t
+	}
+	if err := validateNameTemplate(nameTemplate); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateReplaceMode(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateErrorPolicy(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateInputFormat(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if scanFormat != scanFormatTable && scanFormat != scanFormatJSON {
+		fmt.Fprintf(os.Stderr, "Error: invalid --format value %q (expected table or json)\n", scanFormat)
+		os.Exit(1)
+	}
+	if maxSize != "" {
+		size, err := parseByteSize(maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --max-image-size value: %v\n", err)
+			os.Exit(1)
+		}
+		maxImageSize = size
+	}
+	if scanDepth < 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --depth value %d (expected at least 1)\n", scanDepth)
+		os.Exit(1)
+	}
+
 	var data []byte
 	var err error
 
 	// 获取非标志参数（文件名或 URL）
 	args := flag.Args()
+	if len(args) > 0 {
+		inputName = args[0]
+	}
+	if command == "inline" {
+		// inline 模式只处理 JSON/文本文件，不走图片编码和 base64 解码逻辑
+		if len(args) > 0 {
+			data, err = os.ReadFile(args[0])
+		} else {
+			data, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			os.Exit(exitIOError)
+		}
+		if manifestPath != "" {
+			// 提取时写入的 manifest：裸 base64 数据还原为纯 base64
+			if err := loadBareReferences(manifestPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading manifest: %v\n", err)
+				os.Exit(exitCodeFor(err))
+			}
+		}
+		runInline(data, outputDir, pretty)
+		return
+	}
+	if command == "bundle" {
+		// bundle 模式读取 Markdown/HTML/CSS 文档，把引用的图片内联后输出
+		if len(args) > 0 {
+			data, err = os.ReadFile(args[0])
+		} else {
+			data, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			os.Exit(exitIOError)
+		}
+		runBundle(data)
+		return
+	}
+	if command == "scan" {
+		// scan 模式只读取输入并输出清单，不写入任何文件
+		if len(args) > 0 {
+			data, err = os.ReadFile(args[0])
+		} else {
+			data, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			os.Exit(exitIOError)
+		}
+		runScan(data)
+		finishExtraction()
+		return
+	}
+
 	if len(args) > 0 {
 		input := args[0]
 
@@ -69,8 +277,8 @@ func main() {
 			return
 		}
 
-		// 检查是否是图片文件
-		if isImageFile(input) {
+		// 检查是否是图片文件（指定 --input-format xml 时 SVG 按 XML 处理）
+		if isImageFile(input) && inputFormat != inputXML {
 			// 处理图片文件，生成 base64 文件
 			if err := processImageFile(input, outputDir); err != nil {
 				fmt.Fprintf(os.Stderr, "Error processing image file: %v\n", err)
@@ -89,29 +297,66 @@ func main() {
 			return
 		}
 
+		if stream {
+			// 流式处理，不把整个文件读入内存
+			file, err := os.Open(input)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", input, err)
+				os.Exit(exitIOError)
+			}
+			defer file.Close()
+			runStream(file, outputDir, pretty)
+			finishExtraction()
+			return
+		}
+
 		data, err = os.ReadFile(input)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", input, err)
-			os.Exit(1)
+			os.Exit(exitIOError)
 		}
+	} else if stream {
+		// 流式处理标准输入
+		runStream(os.Stdin, outputDir, pretty)
+		finishExtraction()
+		return
 	} else {
 		// 从标准输入读取
 		data, err = io.ReadAll(os.Stdin)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitIOError)
 		}
 	}
 
+	switch format := detectInputFormat(data); {
+	case format == inputHTML || format == inputCSS:
+		runMarkup(data, format, outputDir, pretty)
+	case format == inputMIME:
+		runMIME(data, outputDir, pretty)
+	case format == inputYAML || format == inputTOML:
+		runDocument(data, format, outputDir, pretty)
+	case format == inputXML:
+		runXML(data, outputDir, pretty)
+	case format == inputText:
+		runText(data, outputDir, pretty)
+	case preserve:
+		runPreserve(data, outputDir, pretty)
+	default:
+		runJSON(data, outputDir, pretty)
+	}
+	finishExtraction()
+}
+
+// runJSON 把输入解析为 JSON 处理，不是 JSON 时作为纯文本处理
+func runJSON(data []byte, outputDir string, pretty bool) {
 	// 尝试解析为 JSON
 	var result interface{}
 	if err := json.Unmarshal(data, &result); err == nil {
-		// 成功解析为 JSON
-
+		// 成功解析为 JSON（单个文档不按 key/custom_id 区分记录）
 		// 处理 base64 图片
-		if err := processImages(result, outputDir); err != nil {
-			fmt.Fprintf(os.Stderr, "Error processing images: %v\n", err)
-			os.Exit(1)
+		if err := processImages(result, "", outputDir); err != nil {
+			abortExtraction("processing images", err)
 		}
 
 		// 输出处理后的 JSON
@@ -122,17 +367,112 @@ func main() {
 			output, err = json.Marshal(result)
 		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
-			os.Exit(1)
+			abortExtraction("marshaling JSON", err)
 		}
 		fmt.Println(string(output))
+	} else if validJSONStream(data) {
+		// JSON Lines 或多个连续的 JSON 文档，逐条处理
+		if err := processJSONRecords(data, outputDir, pretty); err != nil {
+			abortExtraction("processing images", err)
+		}
 	} else {
 		// 不是 JSON，作为纯文本处理
+		runText(data, outputDir, pretty)
+	}
+}
+
+// runText 把输入作为纯文本处理
+func runText(data []byte, outputDir string, pretty bool) {
+	if pretty {
+		fmt.Fprintf(os.Stderr, "Warning: --pretty flag only applies to JSON input, ignoring\n")
+	}
+	text := string(data)
+	processedText, err := processTextContent(text, outputDir)
+	if err != nil {
+		abortExtraction("processing images", err)
+	}
+	fmt.Print(processedText)
+}
+
+// runPreserve 执行保留原始字节的 JSON 改写，只替换提取出的 base64 字符串
+func runPreserve(data []byte, outputDir string, pretty bool) {
+	if !validJSONStream(data) {
+		// 不是 JSON，作为纯文本处理
+		runText(data, outputDir, pretty)
+		return
+	}
+
+	output, err := rewriteJSON(data, outputDir)
+	if err != nil {
+		abortExtraction("processing images", err)
+	}
+
+	// --pretty 时显式重新格式化（字段顺序和数字仍保持不变）
+	if pretty {
+		output, err = indentJSONStream(output)
+		if err != nil {
+			abortExtraction("formatting JSON", err)
+		}
+	}
+	os.Stdout.Write(output)
+}
+
+// runStream 执行流式 JSON 处理
+func runStream(input io.Reader, outputDir string, pretty bool) {
+	if err := streamJSON(input, os.Stdout, outputDir, pretty); err != nil {
+		abortExtraction("streaming JSON", err)
+	}
+}
+
+// runInline 执行 inline 模式：把提取出的图片文件重新内联为 base64
+func runInline(data []byte, outputDir string, pretty bool) {
+	format := detectInputFormat(data)
+	if format == inputHTML || format == inputCSS {
+		output, err := rewriteMarkup(data, format, inlineMarkupURL(outputDir))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error inlining images: %v\n", err)
+			os.Exit(exitCodeFor(err))
+		}
+		os.Stdout.Write(output)
+		return
+	}
+
+	if format != inputText && (json.Valid(data) || validJSONStream(data)) {
+		// 单个 JSON 文档、JSON Lines 或多个连续的文档：逐条还原，只替换文件引用所在的字节范围，其他字节与输入一致
+		output, err := inlineJSON(data, outputDir, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error inlining images: %v\n", err)
+			os.Exit(exitCodeFor(err))
+		}
 		if pretty {
-			fmt.Fprintf(os.Stderr, "Warning: --pretty flag only applies to JSON input, ignoring\n")
+			if output, err = indentJSONStream(output); err != nil {
+				fmt.Fprintf(os.Stderr, "Error formatting JSON: %v\n", err)
+				os.Exit(exitCodeFor(err))
+			}
 		}
-		text := string(data)
-		processedText := processTextContent(text, outputDir)
-		fmt.Print(processedText)
+		os.Stdout.Write(output)
+		return
 	}
+
+	// 不是 JSON，作为 Markdown/纯文本处理
+	if pretty {
+		fmt.Fprintf(os.Stderr, "Warning: --pretty flag only applies to JSON input, ignoring\n")
+	}
+	text, err := inlineTextContent(string(data), outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inlining images: %v\n", err)
+		os.Exit(exitCodeFor(err))
+	}
+	fmt.Print(text)
+}
+
+// flagPassed 判断命令行中是否显式指定了某个参数
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
 }
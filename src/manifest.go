@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"  // 注册 GIF 解码器，用于读取图片尺寸
+	_ "image/jpeg" // 注册 JPEG 解码器，用于读取图片尺寸
+	_ "image/png"  // 注册 PNG 解码器，用于读取图片尺寸
+	"os"
+	"strings"
+)
+
+// blobSource 描述被提取的数据在原文档中的位置
+type blobSource struct {
+	Pointer    string            // JSON 输入：数据所在位置的 JSON Pointer
+	Structured bool              // JSON 输入：是否是结构化格式 {mime_type, data} 中的 data 字段
+	Siblings   map[string]string // JSON 输入：所在对象中其他简单类型字段的值
+	IsText     bool              // 是否是文本输入
+	Offset     int               // 文本输入：数据在文本中的字节偏移
+	Alt        string            // Markdown 图片的 alt 文本
+	Name       string            // Data URL 的 name/filename 参数（已清理为安全的文件名）
+	Bare       bool              // 是否是 --detect-bare 识别出的裸 base64 数据
+}
+
+// jsonSource 返回 JSON 输入中指定位置的 blobSource
+func jsonSource(pointer string, siblings map[string]string) blobSource {
+	return blobSource{Pointer: pointer, Siblings: siblings}
+}
+
+// structuredSource 返回结构化格式 {mime_type, data} 中 data 字段的 blobSource
+func structuredSource(pointer string, siblings map[string]string) blobSource {
+	return blobSource{Pointer: pointer, Structured: true, Siblings: siblings}
+}
+
+// textSource 返回文本输入中指定字节偏移处的 blobSource
+func textSource(offset int) blobSource {
+	return blobSource{IsText: true, Offset: offset}
+}
+
+// manifestEntry manifest 文件中的一条记录，描述一个被提取的数据块
+type manifestEntry struct {
+	Pointer      *string `json:"pointer,omitempty"`
+	Offset       *int    `json:"offset,omitempty"`
+	Record       string  `json:"record,omitempty"`
+	MimeType     string  `json:"mime_type"`
+	DetectedType string  `json:"detected_type,omitempty"`
+	Bytes        int64   `json:"bytes"`
+	SHA256       string  `json:"sha256"`
+	Encoding     string  `json:"encoding"`
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+	Path         string  `json:"path"`
+	Alt          string  `json:"alt,omitempty"`
+	Bare         bool    `json:"bare,omitempty"`
+}
+
+var (
+	manifestPath    string          // 由 --manifest 设置
+	manifestEntries []manifestEntry // 本次运行提取的所有数据块
+	bareReferences  map[string]bool // b64 inline：manifest 中记录为裸 base64 的数据（见 bareReferenceKey）
+)
+
+// recordManifestEntry 记录一个被提取的数据块，head 是数据开头的部分字节，用于检测类型
+func recordManifestEntry(src blobSource, blob savedBlob, head []byte) {
+	entry := manifestEntry{
+		Record:   currentRecord,
+		MimeType: blob.MimeType,
+		Bytes:    blob.Bytes,
+		SHA256:   blob.SHA256,
+		Encoding: blob.Encoding,
+		Width:    blob.Width,
+		Height:   blob.Height,
+		Path:     blob.FullPath,
+		Alt:      src.Alt,
+		Bare:     src.Bare,
+	}
+
+	if src.IsText {
+		offset := src.Offset
+		entry.Offset = &offset
+	} else {
+		pointer := src.Pointer
+		entry.Pointer = &pointer
+	}
+
+	if ext := detectImageType(head); ext != "" {
+		entry.DetectedType = strings.TrimPrefix(ext, ".")
+	}
+
+	manifestEntries = append(manifestEntries, entry)
+}
+
+// finishExtraction 提取完成后的收尾工作：按需写入 manifest 文件，并根据提取结果设置退出码
+func finishExtraction() {
+	if manifestPath != "" {
+		if err := writeManifest(manifestPath); err != nil {
+			abortExtraction("writing manifest", err)
+		}
+	}
+
+	// 读写失败优先于部分成功；没有任何数据提取成功时不算部分成功
+	switch {
+	case ioFailedCount > 0:
+		os.Exit(exitIOError)
+	case failedCount > 0 && extractedCount > 0:
+		os.Exit(exitPartial)
+	case failedCount > 0:
+		os.Exit(exitInvalidInput)
+	case extractedCount == 0:
+		os.Exit(exitNothingFound)
+	}
+}
+
+// writeManifest 把提取记录写入 manifest 文件
+func writeManifest(path string) error {
+	entries := manifestEntries
+	if entries == nil {
+		entries = []manifestEntry{}
+	}
+
+	output, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(output, '\n'), 0644)
+}
+
+// loadBareReferences 读取提取时写入的 manifest 文件，记录其中的裸 base64 数据，
+// b64 inline 把这些位置的文件引用还原为纯 base64 而不是 Data URL
+func loadBareReferences(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+
+	bareReferences = make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Bare && entry.Pointer != nil {
+			bareReferences[bareReferenceKey(*entry.Pointer, entry.Path)] = true
+		}
+	}
+	return nil
+}
+
+// isBareReference 判断 JSON Pointer 位置上引用的文件在提取前是否是裸 base64 数据
+func isBareReference(pointer, path string) bool {
+	return bareReferences[bareReferenceKey(pointer, path)]
+}
+
+// bareReferenceKey 数据的位置和文件的绝对路径（同一个文件可能在多个位置被复用）
+func bareReferenceKey(pointer, path string) string {
+	return pointer + "\x00" + absolutePath(path)
+}
+
+// imageDimensions 从图片开头的字节中读取像素尺寸，无法识别时返回 0, 0
+func imageDimensions(head []byte) (int, int) {
+	switch detectImageType(head) {
+	case ".png", ".jpg", ".gif":
+		if config, _, err := image.DecodeConfig(bytes.NewReader(head)); err == nil {
+			return config.Width, config.Height
+		}
+
+	case ".bmp":
+		// BITMAPINFOHEADER: 宽度在偏移 18，高度在偏移 22（负数表示自上而下存储）
+		if len(head) >= 26 {
+			width := int32(binary.LittleEndian.Uint32(head[18:22]))
+			height := int32(binary.LittleEndian.Uint32(head[22:26]))
+			if height < 0 {
+				height = -height
+			}
+			return int(width), int(height)
+		}
+
+	case ".webp":
+		return webpDimensions(head)
+	}
+	return 0, 0
+}
+
+// webpDimensions 读取 WebP 图片的尺寸（支持 VP8、VP8L 和 VP8X 三种格式）
+func webpDimensions(head []byte) (int, int) {
+	if len(head) < 30 {
+		return 0, 0
+	}
+
+	switch string(head[12:16]) {
+	case "VP8 ":
+		// 帧头起始码 9D 01 2A 之后是 14 位宽度和高度
+		if head[23] == 0x9D && head[24] == 0x01 && head[25] == 0x2A {
+			width := int(binary.LittleEndian.Uint16(head[26:28]) & 0x3FFF)
+			height := int(binary.LittleEndian.Uint16(head[28:30]) & 0x3FFF)
+			return width, height
+		}
+
+	case "VP8L":
+		// 签名 0x2F 之后是 14 位宽度减一和 14 位高度减一
+		if head[20] == 0x2F {
+			bits := binary.LittleEndian.Uint32(head[21:25])
+			return int(bits&0x3FFF) + 1, int((bits>>14)&0x3FFF) + 1
+		}
+
+	case "VP8X":
+		// 画布宽度减一和高度减一，各 24 位
+		width := int(head[24]) | int(head[25])<<8 | int(head[26])<<16
+		height := int(head[27]) | int(head[28])<<8 | int(head[29])<<16
+		return width + 1, height + 1
+	}
+	return 0, 0
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dataURLStart 匹配 Data URL 的开头（scheme 不区分大小写）
+const dataURLStart = `(?i:data):`
+
+// markdownTitlePattern 匹配 Markdown 图片目标之后可选的标题: "title"、'title' 或 (title)
+const markdownTitlePattern = `(?:\s+(?:"[^"]*"|'[^']*'|\([^()]*\)))?`
+
+// embeddedDataURLRe 匹配文本中任意位置的 Data URL，具体的解析由 parseDataURL 完成
+// 四种格式合并为一次匹配，以便记录每个匹配在原文中的字节偏移：
+//   - 行内图片: ![alt](data:... "title") 或 ![alt](<data:...>)，分组 1 为 alt，分组 2 / 3 为（尖括号中的）目标
+//   - 引用式图片的定义: [label]: data:... "title"，分组 4 为标签，分组 5 / 6 为（尖括号中的）目标
+//   - 内嵌的 HTML 标签: <img src="data:..." alt="...">，分组 7 为整个标签
+//   - JSON 转义的 Data URL: data:image\/png;base64,... 或用转义的 \n 分隔的 base64 数据，分组 8
+//   - 普通 Data URL: data:image/png;name=chart.png;base64,... 或百分号编码的 data:image/svg+xml,%3Csvg...，分组 9
+var embeddedDataURLRe = regexp.MustCompile(
+	`!\[([^\]]*)\]\(\s*(?:<(` + dataURLStart + `[^<>\n]*)>|(` + dataURLStart + `[^\s()<>]+(?:\r?\n[^\s()<>"']+)*))` +
+		markdownTitlePattern + `\s*\)` +
+		`|(?m:^)[ ]{0,3}\[([^\]]+)\]:[ \t]*(?:\r?\n[ \t]*)?(?:<(` + dataURLStart + `[^<>\n]*)>|(` + dataURLStart + `\S+))` +
+		`|(<[A-Za-z][\w:-]*\s[^<>]*` + dataURLStart + `[^<>]*>)` +
+		`|(` + escapedDataURLPattern + `)` +
+		`|(` + dataURLStart + dataURLHeaderPattern + `(?:(?i:;base64),` + base64TextPattern + `|,[^\s"'<>()]+))`)
+
+// markdownReferenceRe 匹配引用式图片: ![alt][label] 或 ![label][]
+var markdownReferenceRe = regexp.MustCompile(`!\[([^\]]*)\]\[([^\]]*)\]`)
+
+// embeddedResult 文本中一个 Data URL 的处理结果
+type embeddedResult int
+
+const (
+	embeddedKept    embeddedResult = iota // 不是可提取的数据或提取失败，保留原文
+	embeddedSaved                         // 已保存
+	embeddedDropped                       // 提取失败并且 --on-error skip，删除该引用
+)
+
+// replaceEmbeddedDataURLs 提取文本中所有的 Markdown 图片、HTML 图片标签和 Data URL，并替换为文件引用，
+// 只改写图片的目标，alt、标题、引用定义的标签和周围的文字保持不变
+// source 根据匹配在文本中的字节偏移返回数据的位置，返回新的文本和是否有替换
+func replaceEmbeddedDataURLs(text, outputDir string, source func(offset int) blobSource) (string, bool, error) {
+	var result strings.Builder
+	var alts map[string]string
+	last := 0
+	changed := false
+	for _, loc := range embeddedDataURLRe.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] < last {
+			// 与上一个匹配重叠（删除引用定义时包含了整行）
+			continue
+		}
+		// CRLF 换行的数据最后一行后面的 \r 不属于数据
+		for loc[18] >= 0 && text[loc[1]-1] == '\r' {
+			loc[1]--
+			loc[19]--
+		}
+
+		var replacement string
+		end := loc[1]
+		var err error
+		switch {
+		case loc[2] >= 0:
+			replacement, err = replaceMarkdownImage(text, loc, outputDir, source(loc[0]))
+		case loc[8] >= 0:
+			if alts == nil {
+				alts = markdownReferenceAlts(text)
+			}
+			replacement, end, err = replaceReferenceDefinition(text, loc, alts, outputDir, source(loc[0]))
+		case loc[14] >= 0:
+			replacement, err = replaceEmbeddedTag(text[loc[14]:loc[15]], outputDir, func(offset int) blobSource {
+				return source(loc[14] + offset)
+			})
+		case loc[16] >= 0:
+			replacement, err = replaceDataURL(text[loc[16]:loc[17]], outputDir, source(loc[0]))
+		default:
+			replacement, err = replaceDataURL(text[loc[18]:loc[19]], outputDir, source(loc[0]))
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		if replacement != text[loc[0]:end] {
+			changed = true
+		}
+		result.WriteString(text[last:loc[0]])
+		result.WriteString(replacement)
+		last = end
+	}
+	result.WriteString(text[last:])
+
+	return result.String(), changed, nil
+}
+
+// extractEmbedded 保存文本中的一个 Data URL，提取失败时按 --on-error 处理
+func extractEmbedded(ref, outputDir string, src blobSource) (savedBlob, embeddedResult, error) {
+	u, ok := parseDataURL(ref)
+	if !ok || !isExtractableMimeType(u.MimeType) {
+		return savedBlob{}, embeddedKept, nil
+	}
+	blob, err := saveDataURL(u, outputDir, src)
+	if err != nil {
+		value, replaced, err := handleExtractError(src, err)
+		if err != nil {
+			return savedBlob{}, embeddedKept, err
+		}
+		if replaced && isRemoval(value) {
+			return savedBlob{}, embeddedDropped, nil
+		}
+		return savedBlob{}, embeddedKept, nil
+	}
+	return blob, embeddedSaved, nil
+}
+
+// replaceDataURL 返回替换普通 Data URL 的内容，转义的 Data URL 替换为同样转义的内容
+func replaceDataURL(ref, outputDir string, src blobSource) (string, error) {
+	unescaped, esc := unescapeText(ref)
+	blob, result, err := extractEmbedded(unescaped, outputDir, src)
+	switch result {
+	case embeddedSaved:
+		return esc.escape(replacementText(blob)), err
+	case embeddedDropped:
+		return "", err
+	}
+	return ref, err
+}
+
+// replaceMarkdownImage 返回替换行内图片 ![alt](data:... "title") 的内容
+// 占位文本和删除会替换整个图片，其他方式只替换图片的目标，alt 和标题保持不变
+func replaceMarkdownImage(text string, loc []int, outputDir string, src blobSource) (string, error) {
+	destStart, destEnd, angle := loc[6], loc[7], false
+	if loc[4] >= 0 {
+		destStart, destEnd, angle = loc[4], loc[5], true
+	}
+	src.Alt = text[loc[2]:loc[3]]
+
+	ref, esc := unescapeText(text[destStart:destEnd])
+	blob, result, err := extractEmbedded(ref, outputDir, src)
+	switch {
+	case err != nil:
+		return "", err
+	case result == embeddedDropped:
+		return "", nil
+	case result == embeddedKept:
+		return text[loc[0]:loc[1]], nil
+	}
+
+	switch replaceMode {
+	case replacePlaceholder, replaceRemove:
+		return esc.escape(replacementText(blob)), nil
+	}
+	return text[loc[0]:destStart] + esc.escape(markdownDestination(blobLink(blob), angle)) + text[destEnd:loc[1]], nil
+}
+
+// replaceReferenceDefinition 返回替换引用式图片定义 [label]: data:... 的内容和替换的结束位置
+// 只替换定义中的目标，标签、标题和正文中的 ![alt][label] 保持不变；删除时删除整行定义
+func replaceReferenceDefinition(text string, loc []int, alts map[string]string, outputDir string, src blobSource) (string, int, error) {
+	destStart, destEnd, angle := loc[12], loc[13], false
+	if loc[10] >= 0 {
+		destStart, destEnd, angle = loc[10], loc[11], true
+	}
+	label := text[loc[8]:loc[9]]
+	src.Alt = label
+	if alt, ok := alts[normalizeLabel(label)]; ok && alt != "" {
+		src.Alt = alt
+	}
+
+	blob, result, err := extractEmbedded(text[destStart:destEnd], outputDir, src)
+	switch {
+	case err != nil:
+		return "", 0, err
+	case result == embeddedKept:
+		return text[loc[0]:loc[1]], loc[1], nil
+	case result == embeddedDropped || replaceMode == replaceRemove:
+		return "", lineEnd(text, loc[1]), nil
+	}
+
+	link := markdownDestination(blobLink(blob), angle)
+	if replaceMode == replacePlaceholder {
+		// 占位文本本身带有尖括号
+		link = blobPlaceholder(blob)
+		if angle {
+			link = strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">")
+		}
+	}
+	return text[loc[0]:destStart] + link + text[destEnd:loc[1]], loc[1], nil
+}
+
+// replaceEmbeddedTag 返回替换文本中 HTML 标签（如 <img src="data:...">）的内容
+// 属性的改写与 HTML 输入相同；占位文本会替换整个标签
+func replaceEmbeddedTag(tag, outputDir string, source func(offset int) blobSource) (string, error) {
+	var placeholders []string
+	m := &markupRewriter{data: []byte(tag)}
+	m.resolve = func(ref string, offset int) (interface{}, bool, error) {
+		blob, result, err := extractEmbedded(strings.TrimSpace(ref), outputDir, source(offset))
+		switch {
+		case err != nil:
+			return nil, false, err
+		case result == embeddedKept:
+			return nil, false, nil
+		case result == embeddedDropped:
+			return removeField, true, nil
+		}
+		switch replaceMode {
+		case replacePlaceholder:
+			placeholders = append(placeholders, blobPlaceholder(blob))
+			return removeField, true, nil
+		case replaceRemove:
+			return removeField, true, nil
+		}
+		return blobURL(blob), true, nil
+	}
+
+	if _, _, err := m.scanTag(0); err != nil {
+		return "", err
+	}
+	if len(placeholders) > 0 {
+		return strings.Join(placeholders, " "), nil
+	}
+	return string(applyByteEdits(m.data, m.edits)), nil
+}
+
+// markdownDestination 返回 Markdown 链接的目标：原来使用尖括号时保留尖括号，
+// 包含空白或括号的路径加上尖括号
+func markdownDestination(link string, angle bool) string {
+	if angle {
+		return link
+	}
+	if strings.ContainsAny(link, " \t()<>") {
+		return "<" + link + ">"
+	}
+	return link
+}
+
+// markdownReferenceAlts 返回文本中引用式图片的 alt，键为规范化后的标签
+// 同一个标签被多次引用时使用第一个
+func markdownReferenceAlts(text string) map[string]string {
+	alts := make(map[string]string)
+	for _, m := range markdownReferenceRe.FindAllStringSubmatch(text, -1) {
+		label := m[2]
+		if label == "" {
+			label = m[1]
+		}
+		key := normalizeLabel(label)
+		if _, ok := alts[key]; !ok {
+			alts[key] = m[1]
+		}
+	}
+	return alts
+}
+
+// normalizeLabel 规范化引用标签：不区分大小写，连续的空白视为一个空格
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// lineEnd 返回 i 所在行的结束位置（包括换行符）
+func lineEnd(text string, i int) int {
+	if j := strings.IndexByte(text[i:], '\n'); j >= 0 {
+		return i + j + 1
+	}
+	return len(text)
+}
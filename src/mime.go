@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxMIMEDepth 嵌套的 multipart 和 message/rfc822 部分的最大处理层数
+const maxMIMEDepth = 16
+
+// mimeLineLength 重新编码 base64 正文时每行的字符数（RFC 2045）
+const mimeLineLength = 76
+
+// mimeField 头部中的一个字段，保留原始字节（包括折行和换行符），用于重建消息
+type mimeField struct {
+	name string // 规范化的字段名，如 Content-Type
+	raw  string
+}
+
+// mimePart MIME 消息（.eml、.mhtml）中的一个部分，记录头部和正文在消息中的字节范围
+type mimePart struct {
+	fields     []mimeField
+	header     textproto.MIMEHeader
+	mediaType  string            // 小写的 MIME 类型，没有 Content-Type 时为 text/plain
+	params     map[string]string // Content-Type 的参数
+	delimStart int               // 该部分之前的分隔行（包括分隔行之前的换行）的起始位置，根节点为 -1
+	start      int               // 头部的起始位置
+	bodyStart  int               // 正文的起始位置
+	end        int               // 正文的结束位置（不包括下一个分隔行之前的换行）
+	children   []*mimePart
+}
+
+// mimeRewriter 保留原始字节的 MIME 消息改写器：提取 base64 部分，改写 HTML/CSS 部分中的引用
+type mimeRewriter struct {
+	data      []byte
+	outputDir string
+	newline   string
+	edits     []byteEdit
+	blobs     map[string]savedBlob // cid:Content-ID 和 Content-Location → 提取出的文件
+}
+
+// runMIME 以 MIME 消息处理输入：提取所有 base64 部分，输出把这些部分外置后的消息
+func runMIME(data []byte, outputDir string, pretty bool) {
+	if pretty {
+		fmt.Fprintf(os.Stderr, "Warning: --pretty flag only applies to JSON input, ignoring\n")
+	}
+	if replaceMode == replacePlaceholder {
+		fmt.Fprintf(os.Stderr, "Error: --replace-with placeholder is not supported for MIME input\n")
+		os.Exit(exitInvalidInput)
+	}
+	output, err := rewriteMIME(data, outputDir)
+	if err != nil {
+		abortExtraction("processing MIME parts", err)
+	}
+	os.Stdout.Write(output)
+}
+
+// rewriteMIME 解析 MIME 消息，提取 Content-Transfer-Encoding 为 base64 的部分，
+// 把它们替换为引用提取出的文件的 message/external-body 部分（RFC 2046），
+// 并把 HTML/CSS 部分中的 cid: 和 Content-Location 引用改写为文件路径，返回新的消息
+func rewriteMIME(data []byte, outputDir string) ([]byte, error) {
+	if !flagPassed("types") {
+		// 邮件附件可以是任意类型，默认提取所有类型
+		allowedTypes = []string{"*/*"}
+	}
+
+	m := &mimeRewriter{data: data, outputDir: outputDir, newline: "\n", blobs: make(map[string]savedBlob)}
+	root := parseMIMEPart(data, 0, len(data), -1, 0)
+	if bytes.Contains(data[root.start:root.bodyStart], []byte("\r\n")) {
+		m.newline = "\r\n"
+	}
+
+	var leaves []*mimePart
+	root.walk(func(p *mimePart) {
+		if len(p.children) == 0 {
+			leaves = append(leaves, p)
+		}
+	})
+
+	// 先提取所有部分，HTML 部分通常在它引用的图片之前
+	for _, p := range leaves {
+		if err := m.extractPart(p); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range leaves {
+		if err := m.rewriteMarkupPart(p); err != nil {
+			return nil, err
+		}
+	}
+	return applyByteEdits(data, m.edits), nil
+}
+
+// parseMIMEPart 解析 data[start:end] 中的一个部分，multipart 和 message/rfc822 部分递归解析
+func parseMIMEPart(data []byte, start, end, delimStart, depth int) *mimePart {
+	p := &mimePart{start: start, end: end, delimStart: delimStart, header: make(textproto.MIMEHeader)}
+
+	// 头部到第一个空行为止，以空白开头的行是上一个字段的折行
+	i := start
+	p.bodyStart = end
+	for i < end {
+		j := nextLine(data, i, end)
+		line := string(data[i:j])
+		if strings.TrimRight(line, "\r\n") == "" {
+			p.bodyStart = j
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(p.fields) > 0 {
+			p.fields[len(p.fields)-1].raw += line
+		} else if name, _, ok := strings.Cut(line, ":"); ok {
+			p.fields = append(p.fields, mimeField{name: textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)), raw: line})
+		}
+		i = j
+	}
+	for _, f := range p.fields {
+		_, value, _ := strings.Cut(f.raw, ":")
+		value = strings.Join(strings.Fields(value), " ")
+		p.header.Add(f.name, value)
+	}
+
+	p.mediaType = "text/plain"
+	p.params = make(map[string]string)
+	if contentType := p.header.Get("Content-Type"); contentType != "" {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			mediaType, _, _ = strings.Cut(contentType, ";")
+		}
+		p.mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if params != nil {
+			p.params = params
+		}
+	}
+
+	if depth >= maxMIMEDepth {
+		return p
+	}
+	switch {
+	case strings.HasPrefix(p.mediaType, "multipart/") && p.params["boundary"] != "":
+		p.parseMultipart(data, depth)
+	case p.mediaType == "message/rfc822" && p.transferEncoding() != "base64" && p.transferEncoding() != "quoted-printable":
+		p.children = []*mimePart{parseMIMEPart(data, p.bodyStart, p.end, -1, depth+1)}
+	}
+	return p
+}
+
+// parseMultipart 按 boundary 找出 multipart 正文中的各个部分（RFC 2046 5.1.1）
+// 分隔行之前的换行属于分隔行；缺少结束分隔行时最后一个部分一直到正文结尾
+func (p *mimePart) parseMultipart(data []byte, depth int) {
+	delimiter := "--" + p.params["boundary"]
+
+	type delimiterLine struct {
+		before, after int // 分隔行之前的换行的起始位置，分隔行之后的位置
+		close         bool
+	}
+	var delimiters []delimiterLine
+	for i := p.bodyStart; i < p.end; {
+		j := nextLine(data, i, p.end)
+		line := string(data[i:j])
+		if strings.HasPrefix(line, delimiter) {
+			rest := line[len(delimiter):]
+			closing := strings.HasPrefix(rest, "--")
+			if closing {
+				rest = rest[2:]
+			}
+			if strings.TrimSpace(rest) == "" {
+				before := i
+				if before > p.bodyStart && data[before-1] == '\n' {
+					before--
+					if before > p.bodyStart && data[before-1] == '\r' {
+						before--
+					}
+				}
+				delimiters = append(delimiters, delimiterLine{before: before, after: j, close: closing})
+				if closing {
+					break
+				}
+			}
+		}
+		i = j
+	}
+
+	for k, d := range delimiters {
+		if d.close {
+			break
+		}
+		end := p.end
+		if k+1 < len(delimiters) {
+			end = delimiters[k+1].before
+		}
+		p.children = append(p.children, parseMIMEPart(data, d.after, end, d.before, depth+1))
+	}
+}
+
+// walk 按文档顺序访问该部分和所有子部分
+func (p *mimePart) walk(visit func(*mimePart)) {
+	visit(p)
+	for _, c := range p.children {
+		c.walk(visit)
+	}
+}
+
+// transferEncoding 返回小写的 Content-Transfer-Encoding
+func (p *mimePart) transferEncoding() string {
+	return strings.ToLower(strings.TrimSpace(p.header.Get("Content-Transfer-Encoding")))
+}
+
+// contentID 返回去掉尖括号的 Content-ID
+func (p *mimePart) contentID() string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(p.header.Get("Content-Id")), "<"), ">")
+}
+
+// disposition 返回小写的 Content-Disposition 类型（inline、attachment）和参数
+func (p *mimePart) disposition() (string, map[string]string) {
+	disposition, params, err := mime.ParseMediaType(p.header.Get("Content-Disposition"))
+	if err != nil {
+		return "", nil
+	}
+	return disposition, params
+}
+
+// fileName 返回 Content-Disposition 的 filename 参数或 Content-Type 的 name 参数指定的文件名
+// （解码 RFC 2047 编码并清理为安全的文件名），没有时返回空字符串
+func (p *mimePart) fileName() string {
+	_, params := p.disposition()
+	name := params["filename"]
+	if name == "" {
+		name = p.params["name"]
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	return sanitizeFileName(name)
+}
+
+// extractPart 保存 base64 编码的部分，并把它替换为引用文件的 message/external-body 部分
+// 文本部分（正文的 text/plain、text/html）只有作为附件时才提取
+func (m *mimeRewriter) extractPart(p *mimePart) error {
+	if p.transferEncoding() != "base64" || !isExtractableMimeType(p.mediaType) {
+		return nil
+	}
+	if disposition, _ := p.disposition(); strings.HasPrefix(p.mediaType, "text/") && disposition != "attachment" {
+		return nil
+	}
+
+	src := textSource(p.bodyStart)
+	src.Name = p.fileName()
+	blob, err := saveEncodedStream(bytes.NewReader(m.data[p.bodyStart:p.end]), base64Payload, p.mediaType, m.outputDir, src)
+	if err != nil {
+		value, replaced, err := handleExtractError(src, err)
+		if err != nil || !replaced || !isRemoval(value) {
+			return err
+		}
+		m.removePart(p)
+		return nil
+	}
+
+	if id := p.contentID(); id != "" {
+		m.blobs["cid:"+id] = blob
+	}
+	if location := strings.TrimSpace(p.header.Get("Content-Location")); location != "" {
+		m.blobs[location] = blob
+	}
+
+	if replaceMode == replaceRemove {
+		m.removePart(p)
+		return nil
+	}
+	m.edits = append(m.edits, byteEdit{start: int64(p.start), end: int64(p.end), replacement: m.externalPart(p, blob)})
+	return nil
+}
+
+// removePart 删除一个部分和它之前的分隔行，根节点只删除正文
+func (m *mimeRewriter) removePart(p *mimePart) {
+	if p.delimStart < 0 {
+		m.edits = append(m.edits, byteEdit{start: int64(p.bodyStart), end: int64(p.end)})
+		return
+	}
+	m.edits = append(m.edits, byteEdit{start: int64(p.delimStart), end: int64(p.end)})
+}
+
+// externalPart 返回引用已保存文件的 message/external-body 部分：
+// 外层头部保留原来除 Content-Type 和 Content-Transfer-Encoding 之外的字段，
+// 内层（phantom）头部为原来的 Content-Type 和 Content-ID，正文为空
+func (m *mimeRewriter) externalPart(p *mimePart, blob savedBlob) []byte {
+	params := map[string]string{"access-type": "local-file", "name": blobLink(blob)}
+	if replaceMode == replaceURL {
+		params = map[string]string{"access-type": "URL", "url": blobLink(blob)}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("Content-Type: " + mime.FormatMediaType("message/external-body", params) + m.newline)
+	for _, f := range p.fields {
+		if f.name != "Content-Type" && f.name != "Content-Transfer-Encoding" {
+			b.WriteString(ensureNewline(f.raw, m.newline))
+		}
+	}
+	b.WriteString(m.newline)
+
+	hasContentType := false
+	for _, f := range p.fields {
+		if f.name == "Content-Type" || f.name == "Content-Id" {
+			hasContentType = hasContentType || f.name == "Content-Type"
+			b.WriteString(ensureNewline(f.raw, m.newline))
+		}
+	}
+	if !hasContentType {
+		b.WriteString("Content-Type: " + p.mediaType + m.newline)
+	}
+	b.WriteString(m.newline)
+	return b.Bytes()
+}
+
+// rewriteMarkupPart 把 HTML/CSS 部分中引用已提取部分的 cid: 和 Content-Location URL 改写为文件路径，
+// 其中的 Data URL 同样提取；正文按原来的 Content-Transfer-Encoding 解码后处理，改写后重新编码
+func (m *mimeRewriter) rewriteMarkupPart(p *mimePart) error {
+	var format string
+	switch p.mediaType {
+	case "text/html":
+		format = inputHTML
+	case "text/css":
+		format = inputCSS
+	default:
+		return nil
+	}
+
+	body := m.data[p.bodyStart:p.end]
+	decoded, err := decodeTransferEncoding(body, p.transferEncoding())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to decode %s part at offset %d: %v\n", p.mediaType, p.bodyStart, err)
+		return nil
+	}
+	output, err := rewriteMarkup(decoded, format, m.resolveReference(p))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(output, decoded) {
+		return nil
+	}
+	m.edits = append(m.edits, byteEdit{
+		start:       int64(p.bodyStart),
+		end:         int64(p.end),
+		replacement: encodeTransferEncoding(output, p.transferEncoding(), m.newline),
+	})
+	return nil
+}
+
+// resolveReference 返回 HTML/CSS 部分使用的 markupResolver：
+// cid: URL 和 Content-Location（相对 URL 以该部分的 Content-Location 为基准）指向已提取的部分时改写为文件路径，
+// 其他引用按 HTML 模式提取其中的 Data URL
+func (m *mimeRewriter) resolveReference(p *mimePart) markupResolver {
+	extract := extractMarkupURL(m.outputDir, func(int) blobSource { return textSource(p.bodyStart) })
+	base, _ := url.Parse(strings.TrimSpace(p.header.Get("Content-Location")))
+
+	return func(ref string, offset int) (interface{}, bool, error) {
+		ref = strings.TrimSpace(ref)
+		key := ref
+		if len(ref) > 4 && strings.EqualFold(ref[:4], "cid:") {
+			id, err := url.PathUnescape(ref[4:])
+			if err != nil {
+				id = ref[4:]
+			}
+			key = "cid:" + id
+		}
+
+		blob, ok := m.blobs[key]
+		if !ok && base != nil && !strings.HasPrefix(key, "cid:") {
+			if u, err := url.Parse(ref); err == nil {
+				blob, ok = m.blobs[base.ResolveReference(u).String()]
+			}
+		}
+		if !ok {
+			return extract(ref, offset)
+		}
+		if replaceMode == replaceRemove {
+			return removeField, true, nil
+		}
+		return blobURL(blob), true, nil
+	}
+}
+
+// decodeTransferEncoding 按 Content-Transfer-Encoding 解码正文（7bit、8bit 和 binary 不需要解码）
+func decodeTransferEncoding(body []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "base64":
+		data, _, err := decodeBase64String(string(body))
+		return data, err
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+	}
+	return body, nil
+}
+
+// encodeTransferEncoding 按 Content-Transfer-Encoding 重新编码正文，使用消息原来的换行符
+func encodeTransferEncoding(data []byte, encoding, newline string) []byte {
+	switch encoding {
+	case "base64":
+		encoded := base64.StdEncoding.EncodeToString(data)
+		var b strings.Builder
+		for len(encoded) > mimeLineLength {
+			b.WriteString(encoded[:mimeLineLength] + newline)
+			encoded = encoded[mimeLineLength:]
+		}
+		b.WriteString(encoded)
+		return []byte(b.String())
+	case "quoted-printable":
+		var b bytes.Buffer
+		w := quotedprintable.NewWriter(&b)
+		w.Write(data)
+		w.Close()
+		if newline != "\r\n" {
+			return bytes.ReplaceAll(b.Bytes(), []byte("\r\n"), []byte(newline))
+		}
+		return b.Bytes()
+	}
+	return data
+}
+
+// nextLine 返回 data[i:end] 中第一行之后的位置（包括换行符）
+func nextLine(data []byte, i, end int) int {
+	if j := bytes.IndexByte(data[i:end], '\n'); j >= 0 {
+		return i + j + 1
+	}
+	return end
+}
+
+// ensureNewline 保证头部字段以换行符结尾
+func ensureNewline(s, newline string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + newline
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"mime"
+	"strings"
+)
+
+// mimeExtensions MIME 类型到文件扩展名的对应表
+var mimeExtensions = map[string]string{
+	// 图片
+	"image/png":                ".png",
+	"image/apng":               ".apng",
+	"image/jpeg":               ".jpg",
+	"image/jpg":                ".jpg",
+	"image/pjpeg":              ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/bmp":                ".bmp",
+	"image/x-ms-bmp":           ".bmp",
+	"image/svg+xml":            ".svg",
+	"image/tiff":               ".tiff",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"image/avif":               ".avif",
+	"image/heic":               ".heic",
+	"image/heif":               ".heif",
+
+	// 音频
+	"audio/wav":    ".wav",
+	"audio/x-wav":  ".wav",
+	"audio/wave":   ".wav",
+	"audio/mpeg":   ".mp3",
+	"audio/mp3":    ".mp3",
+	"audio/mp4":    ".m4a",
+	"audio/aac":    ".aac",
+	"audio/ogg":    ".ogg",
+	"audio/opus":   ".opus",
+	"audio/flac":   ".flac",
+	"audio/x-flac": ".flac",
+	"audio/webm":   ".weba",
+	"audio/aiff":   ".aiff",
+	"audio/x-aiff": ".aiff",
+	"audio/l16":    ".pcm",
+	"audio/pcm":    ".pcm",
+
+	// 视频
+	"video/mp4":        ".mp4",
+	"video/mpeg":       ".mpeg",
+	"video/webm":       ".webm",
+	"video/quicktime":  ".mov",
+	"video/x-msvideo":  ".avi",
+	"video/x-matroska": ".mkv",
+	"video/3gpp":       ".3gp",
+	"video/x-flv":      ".flv",
+
+	// 文档和其他
+	"application/pdf":          ".pdf",
+	"application/json":         ".json",
+	"application/xml":          ".xml",
+	"application/zip":          ".zip",
+	"application/gzip":         ".gz",
+	"application/octet-stream": ".bin",
+	"application/rtf":          ".rtf",
+	"application/msword":       ".doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"text/plain":      ".txt",
+	"text/csv":        ".csv",
+	"text/html":       ".html",
+	"text/css":        ".css",
+	"text/markdown":   ".md",
+	"text/xml":        ".xml",
+	"text/javascript": ".js",
+	"font/ttf":        ".ttf",
+	"font/otf":        ".otf",
+	"font/woff":       ".woff",
+	"font/woff2":      ".woff2",
+}
+
+// extensionMimeTypes 文件扩展名到 MIME 类型的对应表（inline 模式还原 Data URL 时使用）
+var extensionMimeTypes = map[string]string{
+	// 图片
+	".png":  "image/png",
+	".apng": "image/apng",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".svg":  "image/svg+xml",
+	".tiff": "image/tiff",
+	".tif":  "image/tiff",
+	".ico":  "image/x-icon",
+	".avif": "image/avif",
+	".heic": "image/heic",
+	".heif": "image/heif",
+
+	// 音频
+	".wav":  "audio/wav",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".opus": "audio/opus",
+	".flac": "audio/flac",
+	".weba": "audio/webm",
+	".aiff": "audio/aiff",
+	".pcm":  "audio/L16",
+
+	// 视频
+	".mp4":  "video/mp4",
+	".mpeg": "video/mpeg",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".avi":  "video/x-msvideo",
+	".mkv":  "video/x-matroska",
+	".3gp":  "video/3gpp",
+	".flv":  "video/x-flv",
+
+	// 文档和其他
+	".pdf":   "application/pdf",
+	".json":  "application/json",
+	".xml":   "application/xml",
+	".zip":   "application/zip",
+	".gz":    "application/gzip",
+	".bin":   "application/octet-stream",
+	".rtf":   "application/rtf",
+	".doc":   "application/msword",
+	".docx":  "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx":  "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".txt":   "text/plain",
+	".csv":   "text/csv",
+	".html":  "text/html",
+	".css":   "text/css",
+	".md":    "text/markdown",
+	".js":    "text/javascript",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// allowedTypes 允许提取的 MIME 类型（--types），支持 image/* 形式的通配符
+var allowedTypes = []string{"image/*"}
+
+// mimeTypeKeys 结构化格式中表示 MIME 类型的字段名
+// mime_type 为原有格式，mimeType 为 Gemini REST API 格式，media_type 为 Anthropic 格式
+var mimeTypeKeys = []string{"mime_type", "mimeType", "media_type"}
+
+// normalizeMimeType 去掉 MIME 类型中的参数部分并转为小写
+func normalizeMimeType(mimeType string) string {
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// extensionForMimeType 根据 MIME 类型返回文件扩展名，未知类型返回 .bin
+func extensionForMimeType(mimeType string) string {
+	mimeType = normalizeMimeType(mimeType)
+	if ext, ok := mimeExtensions[mimeType]; ok {
+		return ext
+	}
+	// 查找系统的 MIME 类型数据库
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// mimeTypeForExtension 根据文件扩展名返回 MIME 类型，未知扩展名返回空字符串
+func mimeTypeForExtension(ext string) string {
+	return extensionMimeTypes[strings.ToLower(ext)]
+}
+
+// parseTypesList 解析 --types 参数（逗号分隔的 MIME 类型列表）
+func parseTypesList(list string) []string {
+	var types []string
+	for _, t := range strings.Split(list, ",") {
+		if t = normalizeMimeType(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// isExtractableMimeType 判断 MIME 类型是否在允许提取的列表中
+func isExtractableMimeType(mimeType string) bool {
+	mimeType = normalizeMimeType(mimeType)
+	if !strings.Contains(mimeType, "/") {
+		return false
+	}
+
+	for _, pattern := range allowedTypes {
+		switch {
+		case pattern == "*" || pattern == "*/*":
+			return true
+		case strings.HasSuffix(pattern, "/*"):
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case pattern == mimeType:
+			return true
+		}
+	}
+	return false
+}
+
+// isMimeTypeKey 判断字段名是否表示结构化格式中的 MIME 类型
+func isMimeTypeKey(key string) bool {
+	for _, k := range mimeTypeKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// structuredMimeType 返回对象中表示 MIME 类型的字段值
+func structuredMimeType(obj map[string]interface{}) (string, bool) {
+	for _, k := range mimeTypeKeys {
+		if mimeType, ok := obj[k].(string); ok {
+			return mimeType, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// maxNestedDepth 字符串中嵌套 JSON 的最大处理层数
+const maxNestedDepth = 8
+
+var (
+	processNested = true // 由 --nested 设置，处理以字符串形式嵌入的 JSON 文档
+	nestedDepth   int    // 当前正在处理的嵌套层数
+)
+
+// nestedJSONDocument 判断字符串是否是需要处理的嵌入 JSON 文档（--nested 开启且未超过最大层数）
+func nestedJSONDocument(str string) bool {
+	trimmed := strings.TrimSpace(str)
+	return processNested && nestedDepth < maxNestedDepth && trimmed != "" &&
+		(trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(str))
+}
+
+// processNestedJSON 处理以字符串形式嵌入的 JSON 文档（如工具调用的 arguments、SSE 日志、队列消息）
+// 内层文档按 --preserve 的方式只替换提取出的数据，其他字节（字段顺序、转义、空白）保持不变，
+// 内层数据的 JSON Pointer 接在该字符串的 JSON Pointer 之后。返回新的字符串和是否有改动
+func processNestedJSON(str, outputDir string, src blobSource) (string, bool, error) {
+	if !nestedJSONDocument(str) {
+		return "", false, nil
+	}
+
+	nestedDepth++
+	defer func() { nestedDepth-- }()
+
+	data := []byte(str)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	rw := &jsonRewriter{data: data, dec: dec, outputDir: outputDir}
+
+	t, err := rw.next()
+	if err != nil {
+		return "", false, nil
+	}
+	if err := rw.visit(t, src.Pointer); err != nil {
+		return "", false, err
+	}
+	if len(rw.edits) == 0 {
+		return "", false, nil
+	}
+	return string(applyByteEdits(data, rw.edits)), true, nil
+}
+
+// inlineNestedJSON 还原以字符串形式嵌入的 JSON 文档中的文件引用（processNestedJSON 的逆操作）
+// 内层文档同样只替换引用所在的字节范围，内层的 JSON Pointer 接在 pointer 之后，返回新的字符串和是否有改动
+func inlineNestedJSON(str, outputDir, pointer string) (string, bool, error) {
+	if !nestedJSONDocument(str) {
+		return "", false, nil
+	}
+
+	nestedDepth++
+	defer func() { nestedDepth-- }()
+
+	output, err := inlineJSON([]byte(str), outputDir, pointer)
+	if err != nil || string(output) == str {
+		return "", false, err
+	}
+	return string(output), true, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pathPattern 解析后的路径表达式，每个元素是一层路径
+// "*" 匹配任意一层，"**" 匹配任意多层（包括零层）
+type pathPattern []string
+
+// pathPatternsFlag 可重复指定的路径表达式参数（--include / --exclude）
+type pathPatternsFlag struct {
+	patterns []pathPattern
+}
+
+var (
+	includePaths pathPatternsFlag // 只提取匹配这些路径的数据
+	excludePaths pathPatternsFlag // 不提取匹配这些路径的数据
+)
+
+// String 实现 flag.Value 接口
+func (f *pathPatternsFlag) String() string {
+	parts := make([]string, len(f.patterns))
+	for i, p := range f.patterns {
+		parts[i] = "/" + strings.Join(p, "/")
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set 实现 flag.Value 接口，每次调用追加一个表达式
+func (f *pathPatternsFlag) Set(expr string) error {
+	p, err := parsePathPattern(expr)
+	if err != nil {
+		return err
+	}
+	f.patterns = append(f.patterns, p)
+	return nil
+}
+
+// parsePathPattern 解析路径表达式
+// 支持 JSON Pointer（/candidates/*/content/parts/*/inlineData）
+// 和 JSONPath 子集（$.candidates[*].content.parts[*].inlineData、$..inlineData）
+func parsePathPattern(expr string) (pathPattern, error) {
+	switch {
+	case expr == "" || expr == "/":
+		return pathPattern{}, nil
+	case strings.HasPrefix(expr, "/"):
+		return parsePointerPattern(expr), nil
+	case strings.HasPrefix(expr, "$"):
+		return parseJSONPathPattern(expr)
+	}
+	return nil, fmt.Errorf("invalid path expression %q: must start with / (JSON Pointer) or $ (JSONPath)", expr)
+}
+
+// parsePointerPattern 解析 JSON Pointer 形式的表达式
+func parsePointerPattern(expr string) pathPattern {
+	return pathPattern(splitPointer(expr))
+}
+
+// parseJSONPathPattern 解析 JSONPath 子集：.name、['name']、[0]、[*]、.*、..（任意层级）
+func parseJSONPathPattern(expr string) (pathPattern, error) {
+	var p pathPattern
+	rest := strings.TrimPrefix(expr, "$")
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			p = append(p, "**")
+			rest = rest[1:] // 保留一个点，继续解析后面的名称
+			if strings.HasPrefix(rest, ".[") {
+				rest = rest[1:]
+			}
+
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty name", expr)
+			}
+			p = append(p, rest[:end])
+			rest = rest[end:]
+
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			quote := rest[1:2]
+			end := strings.Index(rest[2:], quote+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated bracket", expr)
+			}
+			p = append(p, rest[2:2+end])
+			rest = rest[2+end+2:]
+
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated bracket", expr)
+			}
+			p = append(p, strings.TrimSpace(rest[1:end]))
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest)
+		}
+	}
+
+	return p, nil
+}
+
+// matchesPrefix 判断表达式是否匹配路径本身或它的某个上级路径
+func (p pathPattern) matchesPrefix(segments []string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	if p[0] == "**" {
+		// 匹配零层或多层
+		for i := 0; i <= len(segments); i++ {
+			if p[1:].matchesPrefix(segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if p[0] != "*" && p[0] != segments[0] {
+		return false
+	}
+	return p[1:].matchesPrefix(segments[1:])
+}
+
+// pathSelected 根据 --include 和 --exclude 判断指定位置的数据是否需要提取
+func pathSelected(pointer string) bool {
+	if len(includePaths.patterns) == 0 && len(excludePaths.patterns) == 0 {
+		return true
+	}
+
+	segments := splitPointer(pointer)
+	if len(includePaths.patterns) > 0 {
+		included := false
+		for _, p := range includePaths.patterns {
+			if p.matchesPrefix(segments) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, p := range excludePaths.patterns {
+		if p.matchesPrefix(segments) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendPointer 在 JSON Pointer 后追加一层（按 RFC 6901 转义 ~ 和 /）
+func appendPointer(pointer, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return pointer + "/" + token
+}
+
+// appendPointerIndex 在 JSON Pointer 后追加数组下标
+func appendPointerIndex(pointer string, index int) string {
+	return fmt.Sprintf("%s/%d", pointer, index)
+}
+
+// splitPointer 把 JSON Pointer 拆分为各层（并还原转义）
+func splitPointer(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, s := range segments {
+		s = strings.ReplaceAll(s, "~1", "/")
+		segments[i] = strings.ReplaceAll(s, "~0", "~")
+	}
+	return segments
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 批处理记录的命名方式（--record-naming）
+const (
+	recordNamingDir    = "dir"    // 每条记录一个子目录: decoded/<key>/xxx.png
+	recordNamingPrefix = "prefix" // 文件名前缀: decoded/<key>_xxx.png
+	recordNamingNone   = "none"   // 不区分记录
+)
+
+// jsonLinesExtensions JSON Lines 文件的扩展名
+var jsonLinesExtensions = map[string]bool{".jsonl": true, ".ndjson": true}
+
+var (
+	recordNaming  = recordNamingDir // 由 --record-naming 设置
+	currentRecord string            // 当前正在处理的记录标识（已清理，可直接用于路径）
+)
+
+// processJSONRecords 逐条处理 JSON Lines 或多个连续的 JSON 文档，每条记录输出一行
+func processJSONRecords(data []byte, outputDir string, pretty bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var record interface{}
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		setCurrentRecord(record)
+		if err := processImages(record, "", outputDir); err != nil {
+			return err
+		}
+
+		var output []byte
+		if pretty {
+			output, err = json.MarshalIndent(record, "", "  ")
+		} else {
+			output, err = json.Marshal(record)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(output))
+	}
+}
+
+// setCurrentRecord 根据记录的 key 或 custom_id 字段设置当前记录标识
+// Gemini 批处理使用 key，OpenAI 批处理使用 custom_id；只用于 JSON Lines 或多个连续文档中的记录
+func setCurrentRecord(record interface{}) {
+	currentRecord = ""
+	if obj, ok := record.(map[string]interface{}); ok {
+		for _, field := range []string{"key", "custom_id"} {
+			if id, ok := obj[field].(string); ok {
+				setCurrentRecordID(field, id)
+				return
+			}
+		}
+	}
+}
+
+// setCurrentRecordID 设置当前记录标识（只接受 key 和 custom_id 字段）
+func setCurrentRecordID(field, id string) {
+	if recordNaming == recordNamingNone || (field != "key" && field != "custom_id") {
+		return
+	}
+	currentRecord = sanitizeRecordName(id)
+}
+
+// recordIDs 依次返回每个顶层 JSON 文档的记录标识（没有标识的文档对应空字符串）
+func recordIDs(data []byte) []string {
+	var ids []string
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var record struct {
+			Key      interface{} `json:"key"`
+			CustomID interface{} `json:"custom_id"`
+		}
+		if err := dec.Decode(&record); err != nil {
+			// 非对象的顶层文档无法解码到结构体，需要跳过它
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				ids = append(ids, "")
+				continue
+			}
+			return ids
+		}
+
+		id := ""
+		if recordNaming != recordNamingNone {
+			if key, ok := record.Key.(string); ok {
+				id = sanitizeRecordName(key)
+			} else if customID, ok := record.CustomID.(string); ok {
+				id = sanitizeRecordName(customID)
+			}
+		}
+		ids = append(ids, id)
+	}
+}
+
+// sanitizeRecordName 把记录标识转换为安全的文件名（只保留字母、数字、点、下划线和连字符）
+func sanitizeRecordName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+		if b.Len() >= 100 {
+			break
+		}
+	}
+
+	result := strings.Trim(b.String(), ".")
+	if result == "" {
+		return "_"
+	}
+	return result
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// 提取后数据在文档中的替换方式（--replace-with）
+const (
+	replaceRelative    = "rel"         // 相对路径: decoded/xxx.png（默认）
+	replaceAbsolute    = "abs"         // 绝对路径: /home/user/decoded/xxx.png
+	replaceFileURI     = "file-uri"    // file:///home/user/decoded/xxx.png
+	replaceURL         = "url"         // --base-url 加上输出目录中的路径
+	replaceObject      = "object"      // 描述数据的对象 {path, mime_type, bytes, sha256, width, height}
+	replacePlaceholder = "placeholder" // 简短的占位文本: <image/png 1024x1024 812KB>
+	replaceRemove      = "remove"      // 删除字段
+)
+
+var (
+	replaceMode = replaceRelative // 由 --replace-with 设置
+	baseURL     string            // 由 --base-url 设置
+)
+
+// removal 表示删除字段的替换值
+type removal struct{}
+
+// removeField 替换值为 removeField 时删除所在字段（数组元素替换为 null）
+var removeField = removal{}
+
+// isRemoval 判断替换值是否表示删除字段
+func isRemoval(value interface{}) bool {
+	_, ok := value.(removal)
+	return ok
+}
+
+// blobObject --replace-with object 时替换成的对象
+type blobObject struct {
+	Path     string `json:"path"`
+	MimeType string `json:"mime_type"`
+	Bytes    int64  `json:"bytes"`
+	SHA256   string `json:"sha256"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+// validateReplaceMode 检查 --replace-with 和 --base-url 参数
+func validateReplaceMode() error {
+	switch replaceMode {
+	case replaceRelative, replaceAbsolute, replaceFileURI, replaceObject, replacePlaceholder, replaceRemove:
+		return nil
+	case replaceURL:
+		if baseURL == "" {
+			return fmt.Errorf("--replace-with url requires --base-url")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid --replace-with value %q (expected rel, abs, file-uri, url, object, placeholder or remove)", replaceMode)
+}
+
+// replacementValue 返回 JSON 文档中替换数据的值：字符串、blobObject 或 removeField
+func replacementValue(blob savedBlob) interface{} {
+	switch replaceMode {
+	case replaceObject:
+		return blobObject{
+			Path:     blob.Path,
+			MimeType: blob.MimeType,
+			Bytes:    blob.Bytes,
+			SHA256:   blob.SHA256,
+			Width:    blob.Width,
+			Height:   blob.Height,
+		}
+	case replacePlaceholder:
+		return blobPlaceholder(blob)
+	case replaceRemove:
+		return removeField
+	}
+	return blobLink(blob)
+}
+
+// replacementText 返回文本中替换 Data URL 的内容（删除时为空字符串）
+func replacementText(blob savedBlob) string {
+	switch replaceMode {
+	case replacePlaceholder:
+		return blobPlaceholder(blob)
+	case replaceRemove:
+		return ""
+	}
+	return blobLink(blob)
+}
+
+// blobLink 返回指向已保存文件的链接（object 模式在文本中使用相对路径）
+func blobLink(blob savedBlob) string {
+	switch replaceMode {
+	case replaceAbsolute:
+		return absolutePath(blob.FullPath)
+	case replaceFileURI:
+		u := url.URL{Scheme: "file", Path: filepath.ToSlash(absolutePath(blob.FullPath))}
+		if !strings.HasPrefix(u.Path, "/") {
+			// Windows 路径: file:///C:/...
+			u.Path = "/" + u.Path
+		}
+		return u.String()
+	case replaceURL:
+		segments := strings.Split(blob.Name, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		return strings.TrimSuffix(baseURL, "/") + "/" + strings.Join(segments, "/")
+	}
+	return blob.Path
+}
+
+// absolutePath 返回文件的绝对路径，失败时返回原路径
+func absolutePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// blobPlaceholder 返回描述数据的简短占位文本，如 <image/png 1024x1024 812KB>
+func blobPlaceholder(blob savedBlob) string {
+	parts := []string{blob.MimeType}
+	if blob.Width > 0 && blob.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%dx%d", blob.Width, blob.Height))
+	}
+	parts = append(parts, formatSize(blob.Bytes))
+	return "<" + strings.Join(parts, " ") + ">"
+}
+
+// formatSize 把字节数格式化为简短的大小，如 812KB、3.4MB
+func formatSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%dKB", (n+512)/1024)
+	case n < 1024*1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+	}
+	return fmt.Sprintf("%.1fGB", float64(n)/(1024*1024*1024))
+}
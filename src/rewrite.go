@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// byteEdit 表示一次字节范围替换：把 data[start:end] 替换为 replacement
+type byteEdit struct {
+	start, end  int64
+	replacement []byte
+}
+
+// spanToken 带有原始字节范围的 JSON token（只有字符串 token 会记录 start）
+type spanToken struct {
+	tok        json.Token
+	start, end int64
+}
+
+// memberSpan 对象中一个字段的字节范围：从键的起始引号到值的结尾
+type memberSpan struct {
+	start, end int64
+	removed    bool
+}
+
+// jsonRewriter 保留原始字节的 JSON 改写器
+// 只替换提取出的 base64 字符串所在的字节范围，其他字节（字段顺序、数字精度、转义、缩进）保持不变
+type jsonRewriter struct {
+	data       []byte
+	dec        *json.Decoder
+	outputDir  string
+	edits      []byteEdit
+	lastOffset int64
+	siblings   map[string]string // 当前对象中已读取的简单类型字段，用于文件名模板
+}
+
+// rewriteJSON 处理一个或多个 JSON 文档，返回只替换了图片数据的原始字节
+func rewriteJSON(data []byte, outputDir string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	rw := &jsonRewriter{data: data, dec: dec, outputDir: outputDir}
+
+	// 有多个顶层文档时每个文档是一条记录（JSON Lines / 批处理文件）
+	ids := recordIDs(data)
+	if len(ids) < 2 {
+		ids = nil
+	}
+	for i := 0; ; i++ {
+		t, err := rw.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		currentRecord = ""
+		if i < len(ids) {
+			currentRecord = ids[i]
+		}
+		if err := rw.visit(t, ""); err != nil {
+			return nil, err
+		}
+	}
+	currentRecord = ""
+
+	return applyByteEdits(data, rw.edits), nil
+}
+
+// next 读取下一个 token 并记录其字节范围
+func (rw *jsonRewriter) next() (spanToken, error) {
+	tok, err := rw.dec.Token()
+	if err != nil {
+		return spanToken{}, err
+	}
+
+	end := rw.dec.InputOffset()
+	t := spanToken{tok: tok, start: end, end: end}
+	if _, ok := tok.(string); ok {
+		// 两个 token 之间只有空白、逗号和冒号，第一个引号就是字符串的起始位置
+		if i := bytes.IndexByte(rw.data[rw.lastOffset:end], '"'); i >= 0 {
+			t.start = rw.lastOffset + int64(i)
+		}
+	}
+	rw.lastOffset = end
+	return t, nil
+}
+
+// visit 处理一个 JSON 值，t 是该值的第一个 token，pointer 是该值的 JSON Pointer
+func (rw *jsonRewriter) visit(t spanToken, pointer string) error {
+	switch v := t.tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return rw.visitObject(pointer)
+		case '[':
+			return rw.visitArray(pointer)
+		}
+		return fmt.Errorf("%w: unexpected delimiter %q", errInvalidJSON, v)
+
+	case string:
+		// 检查字符串值是否是 Data URL 格式（数组元素被删除时替换为 null）
+		removed, err := rw.visitString(t, v, pointer)
+		if err != nil {
+			return err
+		}
+		if removed {
+			rw.replaceValue(t, nil)
+		}
+	}
+	return nil
+}
+
+// visitString 检查字符串值是否是 Data URL 格式并记录替换，返回替换方式是否为删除
+func (rw *jsonRewriter) visitString(t spanToken, str, pointer string) (bool, error) {
+	if !pathSelected(pointer) {
+		return false, nil
+	}
+	value, replaced, err := processDataURL(str, rw.outputDir, jsonSource(pointer, rw.siblings))
+	return rw.applyReplacement(t, value, replaced), err
+}
+
+// applyReplacement 记录替换操作，返回替换方式是否为删除（删除由调用方处理）
+func (rw *jsonRewriter) applyReplacement(t spanToken, value interface{}, replaced bool) bool {
+	if !replaced {
+		return false
+	}
+	if isRemoval(value) {
+		return true
+	}
+	rw.replaceValue(t, value)
+	return false
+}
+
+// visitObject 处理一个 JSON 对象（起始的 '{' 已被读取）
+func (rw *jsonRewriter) visitObject(pointer string) error {
+	parentSiblings := rw.siblings
+	rw.siblings = make(map[string]string)
+	defer func() { rw.siblings = parentSiblings }()
+
+	var (
+		mimeType    string
+		hasMimeType bool
+		dataToken   *spanToken
+		dataMember  int
+		members     []memberSpan
+	)
+
+	for rw.dec.More() {
+		keyTok, err := rw.next()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+		key, _ := keyTok.tok.(string)
+
+		val, err := rw.next()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		member := memberSpan{start: keyTok.start}
+		str, isString := val.tok.(string)
+		switch {
+		case isString && key == "data" && dataToken == nil && !hasMimeType:
+			// 还不知道 mime_type，等对象结束后再处理
+			dataToken = &val
+			dataMember = len(members)
+		case isString && key == "data" && dataToken == nil:
+			removed, err := rw.visitDataField(val, str, mimeType, appendPointer(pointer, key))
+			if err != nil {
+				return err
+			}
+			member.removed = removed
+		case isString:
+			if isMimeTypeKey(key) && !hasMimeType {
+				mimeType = str
+				hasMimeType = true
+			}
+			removed, err := rw.visitString(val, str, appendPointer(pointer, key))
+			if err != nil {
+				return err
+			}
+			member.removed = removed
+		default:
+			if err := rw.visit(val, appendPointer(pointer, key)); err != nil {
+				return err
+			}
+		}
+		if str, ok := scalarString(val.tok); ok {
+			rw.siblings[key] = str
+		}
+
+		// 值是对象或数组时，visit 返回后 lastOffset 位于结尾的括号之后
+		member.end = rw.lastOffset
+		members = append(members, member)
+	}
+
+	// 读取结束的 '}'
+	if _, err := rw.next(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+
+	if dataToken != nil {
+		removed, err := rw.visitDataField(*dataToken, dataToken.tok.(string), mimeType, appendPointer(pointer, "data"))
+		if err != nil {
+			return err
+		}
+		members[dataMember].removed = removed
+	}
+	rw.removeMembers(members)
+	return nil
+}
+
+// removeMembers 记录删除对象中被标记为删除的字段的操作（连同分隔的逗号一起删除）
+func (rw *jsonRewriter) removeMembers(members []memberSpan) {
+	// 开头连续被删除的字段：删除到第一个保留字段的键之前（包括逗号），全部删除时只删除字段本身
+	first := 0
+	for first < len(members) && members[first].removed {
+		first++
+	}
+	if first > 0 {
+		end := members[first-1].end
+		if first < len(members) {
+			end = members[first].start
+		}
+		rw.edits = append(rw.edits, byteEdit{start: members[0].start, end: end})
+	}
+
+	// 之后被删除的字段：从前一个字段的结尾删除到该字段的结尾（包括前面的逗号）
+	for i := first + 1; i < len(members); i++ {
+		if members[i].removed {
+			rw.edits = append(rw.edits, byteEdit{start: members[i-1].end, end: members[i].end})
+		}
+	}
+}
+
+// visitArray 处理一个 JSON 数组（起始的 '[' 已被读取）
+func (rw *jsonRewriter) visitArray(pointer string) error {
+	// 数组元素没有兄弟字段
+	parentSiblings := rw.siblings
+	rw.siblings = nil
+	defer func() { rw.siblings = parentSiblings }()
+
+	for i := 0; rw.dec.More(); i++ {
+		t, err := rw.next()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+		if err := rw.visit(t, appendPointerIndex(pointer, i)); err != nil {
+			return err
+		}
+	}
+
+	// 读取结束的 ']'
+	if _, err := rw.next(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+	return nil
+}
+
+// visitDataField 处理结构化格式中的 data 字段，mime_type 为允许提取的类型时保存文件并记录替换，
+// 返回替换方式是否为删除
+func (rw *jsonRewriter) visitDataField(t spanToken, data, mimeType, pointer string) (bool, error) {
+	if isExtractableMimeType(mimeType) && pathSelected(pointer) {
+		value, replaced, err := extractBlob(data, mimeType, rw.outputDir, structuredSource(pointer, rw.siblings))
+		return rw.applyReplacement(t, value, replaced), err
+	}
+
+	// 不是结构化图片格式，按普通字符串处理
+	return rw.visitString(t, data, pointer)
+}
+
+// replaceValue 记录把字符串 token 替换为新值（字符串、对象或 null）的操作
+func (rw *jsonRewriter) replaceValue(t spanToken, value interface{}) {
+	rw.edits = append(rw.edits, byteEdit{start: t.start, end: t.end, replacement: encodeJSONValue(value)})
+}
+
+// applyByteEdits 按顺序应用字节替换，返回新的数据
+func applyByteEdits(data []byte, edits []byteEdit) []byte {
+	if len(edits) == 0 {
+		return data
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var buf bytes.Buffer
+	var pos int64
+	for _, e := range edits {
+		buf.Write(data[pos:e.start])
+		buf.Write(e.replacement)
+		pos = e.end
+	}
+	buf.Write(data[pos:])
+	return buf.Bytes()
+}
+
+// encodeJSONValue 把值编码为紧凑的 JSON（不转义 <、> 和 &）
+func encodeJSONValue(value interface{}) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// validJSONStream 检查数据是否由一个或多个完整的 JSON 文档组成
+func validJSONStream(data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	count := 0
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return count > 0
+		}
+		if err != nil {
+			return false
+		}
+		count++
+	}
+}
+
+// indentJSONStream 格式化一个或多个 JSON 文档，保持字段顺序和数字原样
+func indentJSONStream(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var buf bytes.Buffer
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Indent(&buf, raw, "", "  "); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// scan 模式的输出格式（--format）
+const (
+	scanFormatTable = "table"
+	scanFormatJSON  = "json"
+)
+
+var (
+	scanning    bool              // b64 scan：只统计数据，不写入任何文件
+	scanFormat  = scanFormatTable // 由 --format 设置
+	scanDepth   = 2               // 由 --depth 设置，按路径前缀汇总时保留的层数
+	scanEntries []scanEntry       // 本次扫描找到的所有数据块
+)
+
+// scanEntry scan 模式找到的一个数据块
+type scanEntry struct {
+	Location     string  `json:"location"`
+	Pointer      *string `json:"pointer,omitempty"`
+	Line         int     `json:"line,omitempty"`
+	Column       int     `json:"column,omitempty"`
+	Record       string  `json:"record,omitempty"`
+	MimeType     string  `json:"mime_type"`
+	DetectedType string  `json:"detected_type,omitempty"`
+	EncodedBytes int64   `json:"encoded_bytes"`
+	Encoding     string  `json:"encoding"`
+	Bytes        int64   `json:"bytes"`
+	SHA256       string  `json:"sha256"`
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+
+	offset int // 文本输入：字节偏移，输出前换算为行号和列号
+	isText bool
+}
+
+// scanPrefixTotal 按路径前缀汇总的 base64 数据量
+type scanPrefixTotal struct {
+	Prefix       string `json:"prefix"`
+	Blobs        int    `json:"blobs"`
+	EncodedBytes int64  `json:"encoded_bytes"`
+	Bytes        int64  `json:"bytes"`
+}
+
+// scanReport scan 模式的 JSON 输出
+type scanReport struct {
+	DocumentBytes int64             `json:"document_bytes"`
+	EncodedBytes  int64             `json:"encoded_bytes"`
+	Bytes         int64             `json:"bytes"`
+	Blobs         []scanEntry       `json:"blobs"`
+	Prefixes      []scanPrefixTotal `json:"prefixes"`
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read 实现 io.Reader 接口
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// scanBlob 解码数据但不写入文件，只记录数据的位置、类型和大小
+func scanBlob(r io.Reader, encoding payloadEncoding, mimeType string, src blobSource) (savedBlob, error) {
+	counter := &countingReader{r: r}
+	stats := &blobWriter{hash: sha256.New()}
+	dec := newPayloadDecoder(counter, encoding)
+	if _, err := io.Copy(stats, dec); err != nil {
+		return savedBlob{}, fmt.Errorf("failed to decode base64: %w", err)
+	}
+
+	entry := scanEntry{
+		Record:       currentRecord,
+		MimeType:     normalizeMimeType(mimeType),
+		EncodedBytes: counter.n,
+		Encoding:     dec.Encoding(),
+		Bytes:        stats.size,
+		SHA256:       hex.EncodeToString(stats.hash.Sum(nil)),
+		offset:       src.Offset,
+		isText:       src.IsText,
+	}
+	if !src.IsText {
+		pointer := src.Pointer
+		entry.Pointer = &pointer
+	}
+	if ext := detectImageType(stats.head); ext != "" {
+		entry.DetectedType = strings.TrimPrefix(ext, ".")
+		entry.Width, entry.Height = imageDimensions(stats.head)
+	}
+	scanEntries = append(scanEntries, entry)
+	extractedCount++
+
+	return savedBlob{
+		MimeType: entry.MimeType,
+		Bytes:    entry.Bytes,
+		SHA256:   entry.SHA256,
+		Encoding: entry.Encoding,
+		Width:    entry.Width,
+		Height:   entry.Height,
+	}, nil
+}
+
+// runScan 执行 scan 模式：找出输入中的所有数据块并输出清单，不写入任何文件
+func runScan(data []byte) {
+	scanning = true
+	manifestPath = "" // 不写入任何文件，包括 manifest
+
+	format := detectInputFormat(data)
+	var result interface{}
+	if format == inputHTML || format == inputCSS {
+		if _, err := rewriteMarkup(data, format, extractMarkupURL("", textSource)); err != nil {
+			abortExtraction("scanning", err)
+		}
+	} else if format == inputMIME {
+		if _, err := rewriteMIME(data, ""); err != nil {
+			abortExtraction("scanning", err)
+		}
+	} else if format == inputYAML || format == inputTOML || format == inputXML {
+		if _, err := rewriteDocument(data, format, ""); err == errInvalidDocument {
+			if _, err := processTextContent(string(data), ""); err != nil {
+				abortExtraction("scanning", err)
+			}
+		} else if err != nil {
+			abortExtraction("scanning", err)
+		}
+	} else if format == inputText {
+		if _, err := processTextContent(string(data), ""); err != nil {
+			abortExtraction("scanning", err)
+		}
+	} else if err := json.Unmarshal(data, &result); err == nil {
+		if err := processImages(result, "", ""); err != nil {
+			abortExtraction("scanning", err)
+		}
+	} else if validJSONStream(data) {
+		// JSON Lines 或多个连续的 JSON 文档，逐条扫描
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var record interface{}
+			if err := dec.Decode(&record); err == io.EOF {
+				break
+			} else if err != nil {
+				abortExtraction("scanning", fmt.Errorf("%w: %w", errInvalidJSON, err))
+			}
+			setCurrentRecord(record)
+			if err := processImages(record, "", ""); err != nil {
+				abortExtraction("scanning", err)
+			}
+		}
+		currentRecord = ""
+	} else if _, err := processTextContent(string(data), ""); err != nil {
+		abortExtraction("scanning", err)
+	}
+
+	report := buildScanReport(data)
+	var err error
+	if scanFormat == scanFormatJSON {
+		err = writeScanJSON(os.Stdout, report)
+	} else {
+		err = writeScanTable(os.Stdout, report)
+	}
+	if err != nil {
+		abortExtraction("writing scan report", err)
+	}
+}
+
+// buildScanReport 补全数据块的位置信息并按路径前缀汇总
+func buildScanReport(data []byte) scanReport {
+	report := scanReport{DocumentBytes: int64(len(data)), Blobs: scanEntries}
+	if report.Blobs == nil {
+		report.Blobs = []scanEntry{}
+	}
+
+	totals := make(map[string]*scanPrefixTotal)
+	for i := range report.Blobs {
+		entry := &report.Blobs[i]
+		var prefix string
+		if entry.isText {
+			entry.Line, entry.Column = lineColumn(data, entry.offset)
+			entry.Location = fmt.Sprintf("%d:%d", entry.Line, entry.Column)
+			prefix = "(text)"
+		} else {
+			entry.Location = *entry.Pointer
+			if entry.Location == "" {
+				entry.Location = "/"
+			}
+			prefix = pointerPrefix(*entry.Pointer, scanDepth)
+		}
+		if entry.Record != "" {
+			entry.Location = entry.Record + ":" + entry.Location
+		}
+
+		report.EncodedBytes += entry.EncodedBytes
+		report.Bytes += entry.Bytes
+
+		total, ok := totals[prefix]
+		if !ok {
+			total = &scanPrefixTotal{Prefix: prefix}
+			totals[prefix] = total
+		}
+		total.Blobs++
+		total.EncodedBytes += entry.EncodedBytes
+		total.Bytes += entry.Bytes
+	}
+
+	// 按数据量从大到小排列
+	report.Prefixes = []scanPrefixTotal{}
+	for _, total := range totals {
+		report.Prefixes = append(report.Prefixes, *total)
+	}
+	sort.Slice(report.Prefixes, func(i, j int) bool {
+		a, b := report.Prefixes[i], report.Prefixes[j]
+		if a.EncodedBytes != b.EncodedBytes {
+			return a.EncodedBytes > b.EncodedBytes
+		}
+		return a.Prefix < b.Prefix
+	})
+	return report
+}
+
+// pointerPrefix 截取 JSON Pointer 的前 depth 层，数组下标替换为 *，以便汇总所有数组元素
+func pointerPrefix(pointer string, depth int) string {
+	segments := splitPointer(pointer)
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	prefix := ""
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segment = "*"
+		}
+		prefix = appendPointer(prefix, segment)
+	}
+	if prefix == "" {
+		return "/"
+	}
+	return prefix
+}
+
+// lineColumn 把字节偏移换算为行号和列号（从 1 开始，列号按字节计算）
+func lineColumn(data []byte, offset int) (int, int) {
+	before := data[:min(offset, len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
+
+// writeScanJSON 以 JSON 格式输出扫描结果
+func writeScanJSON(w io.Writer, report scanReport) error {
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
+
+// writeScanTable 以表格格式输出扫描结果
+func writeScanTable(w io.Writer, report scanReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOCATION\tMIME TYPE\tFORMAT\tSIZE\tENCODING\tBASE64\tDECODED")
+	for _, entry := range report.Blobs {
+		format := entry.DetectedType
+		if format == "" {
+			format = "-"
+		}
+		size := "-"
+		if entry.Width > 0 && entry.Height > 0 {
+			size = fmt.Sprintf("%dx%d", entry.Width, entry.Height)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Location, entry.MimeType, format, size, entry.Encoding,
+			formatSize(entry.EncodedBytes), formatSize(entry.Bytes))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PREFIX\tBLOBS\tBASE64\tDECODED\tOF DOCUMENT")
+	for _, total := range report.Prefixes {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", total.Prefix, total.Blobs,
+			formatSize(total.EncodedBytes), formatSize(total.Bytes), percent(total.EncodedBytes, report.DocumentBytes))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d blob(s), %s of base64 in a %s document (%s)\n", len(report.Blobs),
+		formatSize(report.EncodedBytes), formatSize(report.DocumentBytes), percent(report.EncodedBytes, report.DocumentBytes))
+	return err
+}
+
+// percent 返回 part 占 whole 的百分比
+func percent(part, whole int64) string {
+	if whole == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(whole))
+}
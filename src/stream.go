@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// jsonStreamer 基于 json.Decoder 的流式处理器
+// 逐个读取 token，遇到 base64 图片时直接解码写入磁盘，处理后的 JSON 边处理边输出，
+// 内存占用只与单个字符串 token 的大小有关，与整个文档的大小无关
+type jsonStreamer struct {
+	dec       *json.Decoder
+	outputDir string
+	pretty    bool
+	records   bool              // 按 key/custom_id 区分记录（.jsonl/.ndjson 输入）
+	siblings  map[string]string // 当前对象中已读取的简单类型字段，用于文件名模板
+}
+
+// streamJSON 流式处理输入中的 JSON 文档（支持多个连续的文档），结果写入 w
+func streamJSON(r io.Reader, w io.Writer, outputDir string, pretty bool) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber() // 保持数字原样输出
+
+	bw := bufio.NewWriter(w)
+	// 流式处理时无法预先知道是否有多个文档，只有 JSON Lines 文件按记录区分
+	records := jsonLinesExtensions[strings.ToLower(filepath.Ext(inputName))]
+	s := &jsonStreamer{dec: dec, outputDir: outputDir, pretty: pretty, records: records}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		// JSON Lines 中每个顶层文档是一条记录，记录标识在读到 key 或 custom_id 字段时设置
+		currentRecord = ""
+		if err := s.writeValue(bw, tok, 0, ""); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	currentRecord = ""
+	return bw.Flush()
+}
+
+// writeValue 输出一个 JSON 值，tok 是该值的第一个 token，pointer 是该值的 JSON Pointer
+func (s *jsonStreamer) writeValue(w io.Writer, tok json.Token, depth int, pointer string) error {
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return s.writeObject(w, depth, pointer)
+		case '[':
+			return s.writeArray(w, depth, pointer)
+		}
+		return fmt.Errorf("%w: unexpected delimiter %q", errInvalidJSON, t)
+
+	case string:
+		// 检查字符串值是否是 Data URL 格式（数组元素被删除时输出 null）
+		value, _, err := s.stringReplacement(t, "", pointer, false)
+		if err != nil {
+			return err
+		}
+		if isRemoval(value) {
+			value = nil
+		}
+		return s.writeReplacement(w, value, depth)
+
+	default:
+		// json.Number、bool、nil 直接编码
+		encoded, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(encoded)
+		return err
+	}
+}
+
+// writeObject 输出一个 JSON 对象（起始的 '{' 已被读取）
+//
+// 结构化格式 {mime_type, data} 中 data 可能出现在 mime_type 之前，
+// 此时先暂存 data 字段，后续字段写入缓冲区，直到读到 mime_type 或对象结束再一并输出
+func (s *jsonStreamer) writeObject(w io.Writer, depth int, pointer string) error {
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+
+	parentSiblings := s.siblings
+	s.siblings = make(map[string]string)
+	defer func() { s.siblings = parentSiblings }()
+
+	var (
+		mimeType     string
+		hasMimeType  bool
+		pendingData  *string
+		pendingIndex int
+		buffered     bytes.Buffer
+		out          = w
+		count        int
+	)
+
+	// flushPending 输出暂存的 data 字段和缓冲区中的后续字段
+	flushPending := func() error {
+		if pendingData == nil {
+			return nil
+		}
+		written, err := s.writeStringMember(w, "data", *pendingData, mimeType, true, pendingIndex, depth, appendPointer(pointer, "data"))
+		if err != nil {
+			return err
+		}
+		rest := buffered.Bytes()
+		if !written {
+			// data 字段被删除：不再占用位置，是第一个字段时去掉后续字段前的逗号
+			count--
+			if pendingIndex == 0 {
+				rest = bytes.TrimPrefix(rest, []byte(","))
+			}
+		}
+		if _, err := w.Write(rest); err != nil {
+			return err
+		}
+		pendingData = nil
+		out = w
+		return nil
+	}
+
+	for s.dec.More() {
+		keyTok, err := s.dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return fmt.Errorf("%w: unexpected object key %v", errInvalidJSON, keyTok)
+		}
+
+		valTok, err := s.dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		if str, ok := valTok.(string); ok && key == "data" {
+			if !hasMimeType && pendingData == nil {
+				// 还不知道 mime_type，先暂存
+				pendingData = &str
+				pendingIndex = count
+				count++
+				out = &buffered
+				continue
+			}
+			written, err := s.writeStringMember(out, key, str, mimeType, true, count, depth, appendPointer(pointer, key))
+			if err != nil {
+				return err
+			}
+			if written {
+				count++
+			}
+			continue
+		}
+
+		if str, ok := valTok.(string); ok {
+			// 字符串字段可能是需要删除的 Data URL，先处理再决定是否输出键
+			written, err := s.writeStringMember(out, key, str, "", false, count, depth, appendPointer(pointer, key))
+			if err != nil {
+				return err
+			}
+			if written {
+				count++
+			}
+		} else {
+			if err := s.writeKey(out, key, count, depth); err != nil {
+				return err
+			}
+			count++
+			if err := s.writeValue(out, valTok, depth+1, appendPointer(pointer, key)); err != nil {
+				return err
+			}
+		}
+		if str, ok := scalarString(valTok); ok {
+			s.siblings[key] = str
+		}
+
+		if str, ok := valTok.(string); ok && depth == 0 && s.records && currentRecord == "" {
+			// 顶层对象的 key/custom_id 字段（需要出现在图片数据之前才能生效）
+			setCurrentRecordID(key, str)
+		}
+		if str, ok := valTok.(string); ok && isMimeTypeKey(key) && !hasMimeType {
+			mimeType = str
+			hasMimeType = true
+			if err := flushPending(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 读取结束的 '}'
+	if _, err := s.dec.Token(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+	if err := flushPending(); err != nil {
+		return err
+	}
+
+	if s.pretty && count > 0 {
+		if err := s.writeIndent(w, depth); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+// writeArray 输出一个 JSON 数组（起始的 '[' 已被读取）
+func (s *jsonStreamer) writeArray(w io.Writer, depth int, pointer string) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	// 数组元素没有兄弟字段
+	parentSiblings := s.siblings
+	s.siblings = nil
+	defer func() { s.siblings = parentSiblings }()
+
+	count := 0
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidJSON, err)
+		}
+
+		if count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if s.pretty {
+			if err := s.writeIndent(w, depth+1); err != nil {
+				return err
+			}
+		}
+		if err := s.writeValue(w, tok, depth+1, appendPointerIndex(pointer, count)); err != nil {
+			return err
+		}
+		count++
+	}
+
+	// 读取结束的 ']'
+	if _, err := s.dec.Token(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidJSON, err)
+	}
+
+	if s.pretty && count > 0 {
+		if err := s.writeIndent(w, depth); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// stringReplacement 处理一个字符串值，返回替换值（见 replacementValue）和是否被提取
+// structured 表示是结构化格式中的 data 字段，mime_type 为允许提取的类型时保存文件；
+// 否则按 Data URL 格式检查。未被提取时返回原字符串
+func (s *jsonStreamer) stringReplacement(str, mimeType, pointer string, structured bool) (interface{}, bool, error) {
+	if !pathSelected(pointer) {
+		return str, false, nil
+	}
+	var value interface{}
+	var replaced bool
+	var err error
+	if structured && isExtractableMimeType(mimeType) {
+		value, replaced, err = extractBlob(str, mimeType, s.outputDir, structuredSource(pointer, s.siblings))
+	} else {
+		value, replaced, err = processDataURL(str, s.outputDir, jsonSource(pointer, s.siblings))
+	}
+	if err != nil || !replaced {
+		return str, false, err
+	}
+	return value, true, nil
+}
+
+// writeStringMember 输出对象中值为字符串的字段，返回是否输出（替换方式为删除时不输出）
+func (s *jsonStreamer) writeStringMember(w io.Writer, key, str, mimeType string, structured bool, index, depth int, pointer string) (bool, error) {
+	value, _, err := s.stringReplacement(str, mimeType, pointer, structured)
+	if err != nil {
+		return false, err
+	}
+	if isRemoval(value) {
+		return false, nil
+	}
+	if err := s.writeKey(w, key, index, depth); err != nil {
+		return false, err
+	}
+	return true, s.writeReplacement(w, value, depth+1)
+}
+
+// writeReplacement 输出替换值（字符串或对象），depth 是该值所在的层级
+func (s *jsonStreamer) writeReplacement(w io.Writer, value interface{}, depth int) error {
+	if str, ok := value.(string); ok {
+		return writeJSONString(w, str)
+	}
+
+	encoded := encodeJSONValue(value)
+	if s.pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, encoded, strings.Repeat("  ", depth), "  "); err != nil {
+			return err
+		}
+		encoded = buf.Bytes()
+	}
+	_, err := w.Write(encoded)
+	return err
+}
+
+// writeKey 输出对象的键（包括前面的逗号和缩进）
+func (s *jsonStreamer) writeKey(w io.Writer, key string, index, depth int) error {
+	if index > 0 {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	if s.pretty {
+		if err := s.writeIndent(w, depth+1); err != nil {
+			return err
+		}
+	}
+	if err := writeJSONString(w, key); err != nil {
+		return err
+	}
+	separator := ":"
+	if s.pretty {
+		separator = ": "
+	}
+	_, err := io.WriteString(w, separator)
+	return err
+}
+
+// writeIndent 输出换行和缩进（与 json.MarshalIndent 的格式一致）
+func (s *jsonStreamer) writeIndent(w io.Writer, depth int) error {
+	_, err := io.WriteString(w, "\n"+strings.Repeat("  ", depth))
+	return err
+}
+
+// writeJSONString 把字符串编码为 JSON 字符串输出（不转义 <、> 和 &，与输入一致）
+func writeJSONString(w io.Writer, str string) error {
+	_, err := w.Write(encodeJSONValue(str))
+	return err
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nameTemplate 由 --name-template 设置的文件名模板，为空时使用 --naming 的命名方式
+var nameTemplate string
+
+// templatePlaceholderRe 匹配模板中的占位符: {name} 或 {sibling.field}
+var templatePlaceholderRe = regexp.MustCompile(`\{([A-Za-z0-9_]+(?:\.[^{}]+)?)\}`)
+
+// templatePlaceholders 支持的占位符（sibling.* 另外处理）
+var templatePlaceholders = map[string]bool{
+	"pointer":      true, // JSON Pointer（/ 替换为 _）或文本偏移
+	"key":          true, // 数据所在的字段名
+	"index":        true, // 本次运行中的提取序号（从 1 开始）
+	"alt":          true, // Markdown 图片的 alt 文本（转换为 slug）
+	"hash":         true, // 内容 SHA-256 的前 --hash-length 位
+	"hash8":        true, // 内容 SHA-256 的前 8 位
+	"date":         true, // 日期 YYYY-MM-DD
+	"time":         true, // 时间 HHMMSS
+	"record":       true, // 批处理记录标识
+	"mime_type":    true, // MIME 类型（/ 替换为 _）
+	"mime_subtype": true, // MIME 子类型，如 png、svg+xml
+	"ext":          true, // 扩展名，如 .png
+	"name":         true, // Data URL 的 name/filename 参数（不含扩展名）
+}
+
+// templateValues 渲染模板时使用的值
+type templateValues struct {
+	src      blobSource
+	mimeType string
+	ext      string
+	sum      string
+	index    uint64
+}
+
+// validateNameTemplate 检查模板中的占位符是否都受支持
+func validateNameTemplate(tmpl string) error {
+	for _, m := range templatePlaceholderRe.FindAllStringSubmatch(tmpl, -1) {
+		name := m[1]
+		if strings.HasPrefix(name, "sibling.") || templatePlaceholders[name] {
+			continue
+		}
+		return fmt.Errorf("unknown placeholder {%s} in name template", name)
+	}
+	return nil
+}
+
+// renderNameTemplate 渲染文件名模板，返回相对于输出目录的安全路径
+// 每一层路径都会被清理，不会出现绝对路径、.. 或空的路径层
+func renderNameTemplate(tmpl string, v templateValues) string {
+	rendered := templatePlaceholderRe.ReplaceAllStringFunc(tmpl, func(match string) string {
+		// 占位符的值中不能出现路径分隔符，只有模板本身的 / 才会创建子目录
+		return strings.NewReplacer("/", "_", "\\", "_").Replace(placeholderValue(match, v))
+	})
+
+	// 逐层清理路径，防止路径穿越
+	var segments []string
+	for _, segment := range strings.Split(strings.ReplaceAll(rendered, "\\", "/"), "/") {
+		if strings.Trim(segment, ". ") == "" {
+			continue
+		}
+		segments = append(segments, sanitizeRecordName(segment))
+	}
+	if len(segments) == 0 {
+		segments = []string{v.sum[:min(hashLength, len(v.sum))]}
+	}
+
+	// 模板中没有扩展名时自动添加
+	result := path.Join(segments...)
+	if !strings.HasSuffix(strings.ToLower(result), v.ext) {
+		result += v.ext
+	}
+	return result
+}
+
+// placeholderValue 返回单个占位符（如 {hash8}）的值
+func placeholderValue(match string, v templateValues) string {
+	name := match[1 : len(match)-1]
+	if field, ok := strings.CutPrefix(name, "sibling."); ok {
+		return v.src.Siblings[field]
+	}
+
+	switch name {
+	case "pointer":
+		if v.src.IsText {
+			return strconv.Itoa(v.src.Offset)
+		}
+		return strings.ReplaceAll(strings.TrimPrefix(v.src.Pointer, "/"), "/", "_")
+	case "key":
+		return v.src.key()
+	case "index":
+		return strconv.FormatUint(v.index, 10)
+	case "alt":
+		return slugify(v.src.Alt)
+	case "hash":
+		return v.sum[:min(hashLength, len(v.sum))]
+	case "hash8":
+		return v.sum[:8]
+	case "date":
+		return currentTime().Format("2006-01-02")
+	case "time":
+		return currentTime().Format("150405")
+	case "record":
+		return currentRecord
+	case "mime_type":
+		return strings.ReplaceAll(normalizeMimeType(v.mimeType), "/", "_")
+	case "mime_subtype":
+		mimeType := normalizeMimeType(v.mimeType)
+		return mimeType[strings.IndexByte(mimeType, '/')+1:]
+	case "ext":
+		return v.ext
+	case "name":
+		return strings.TrimSuffix(v.src.Name, path.Ext(v.src.Name))
+	}
+	return match
+}
+
+// key 返回数据所在的字段名（结构化格式中为包含 data 字段的对象的字段名）
+func (src blobSource) key() string {
+	segments := splitPointer(src.Pointer)
+	if src.Structured && len(segments) > 0 {
+		segments = segments[:len(segments)-1]
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(segments[i]); err != nil {
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+// slugify 把任意文本转换为适合文件名的 slug（小写字母、数字和连字符）
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// scalarSiblings 返回对象中简单类型字段的字符串值，用于模板中的 {sibling.field}
+// 过长的字符串（如 base64 数据本身）会被忽略
+func scalarSiblings(obj map[string]interface{}) map[string]string {
+	siblings := make(map[string]string)
+	for key, value := range obj {
+		if str, ok := scalarString(value); ok {
+			siblings[key] = str
+		}
+	}
+	return siblings
+}
+
+// scalarString 把简单类型的 JSON 值转换为字符串
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		if len(v) > 200 {
+			return "", false
+		}
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlBareKeyRe 匹配 TOML 的裸键
+var tomlBareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlDateTimeRe 匹配 TOML 的日期和时间（作为字符串处理）
+var tomlDateTimeRe = regexp.MustCompile(`^(?:\d{4}-\d{2}-\d{2}(?:[Tt ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:[Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?)`)
+
+// tomlEscapes 基本字符串中的单字符转义序列
+var tomlEscapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", 'e': "\x1b", '"': "\"", '\\': "\\",
+}
+
+// tomlParser 解析 TOML 文档并记录每个值的字节范围
+type tomlParser struct {
+	data string
+	pos  int
+}
+
+// parseTOML 解析 TOML 输入，返回根表
+// [table] 和 [[array]] 的表头以及点分隔的键隐式创建的表没有可以删除的字节范围
+func parseTOML(data []byte) (*docNode, error) {
+	p := &tomlParser{data: string(data)}
+	root := &docNode{kind: docMapping}
+	current := root
+	for p.pos < len(p.data) {
+		lineStart := p.pos
+		i := p.skipSpaces(p.pos)
+		switch c := p.byteAt(i); {
+		case c == 0 || c == '\r' || c == '\n' || c == '#':
+			p.pos = lineEnd(p.data, i)
+			continue
+
+		case c == '[':
+			array := p.byteAt(i+1) == '['
+			j := i + 1
+			if array {
+				j++
+			}
+			keys, j, err := p.parseKey(j)
+			if err != nil {
+				return nil, err
+			}
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			if !strings.HasPrefix(p.data[j:], closing) {
+				return nil, p.errorf(j, "expected %q", closing)
+			}
+			if err := p.finishLine(j + len(closing)); err != nil {
+				return nil, err
+			}
+
+			table, err := p.table(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			if !array {
+				current, err = p.table(table, []string{last})
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			e := table.find(last)
+			if e == nil {
+				table.entries = append(table.entries, docEntry{key: last, node: &docNode{kind: docSequence}, start: -1, end: -1})
+				e = &table.entries[len(table.entries)-1]
+			}
+			if e.node.kind != docSequence || e.node.inline {
+				return nil, p.errorf(i, "key %q is not an array of tables", last)
+			}
+			current = &docNode{kind: docMapping}
+			e.node.entries = append(e.node.entries, docEntry{node: current, start: -1, end: -1})
+
+		default:
+			keys, node, err := p.parseKeyValue(i)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.finishLine(node.end); err != nil {
+				return nil, err
+			}
+			table, err := p.table(current, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			if table.find(last) != nil {
+				return nil, p.errorf(i, "duplicate key %q", last)
+			}
+			table.entries = append(table.entries, docEntry{key: last, node: node, start: lineStart, end: p.pos})
+		}
+	}
+	return root, nil
+}
+
+// errorf 返回带行号的解析错误
+func (p *tomlParser) errorf(i int, format string, args ...interface{}) error {
+	line := strings.Count(p.data[:i], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// table 返回 parent 中按 keys 逐级查找的表，不存在时隐式创建；表数组使用最后一个元素
+func (p *tomlParser) table(parent *docNode, keys []string) (*docNode, error) {
+	for _, key := range keys {
+		e := parent.find(key)
+		if e == nil {
+			parent.entries = append(parent.entries, docEntry{key: key, node: &docNode{kind: docMapping}, start: -1, end: -1})
+			e = &parent.entries[len(parent.entries)-1]
+		}
+		node := e.node
+		if node.kind == docSequence && !node.inline && len(node.entries) > 0 {
+			node = node.entries[len(node.entries)-1].node
+		}
+		if node.kind != docMapping {
+			return nil, fmt.Errorf("key %q is not a table", key)
+		}
+		parent = node
+	}
+	return parent, nil
+}
+
+// parseKeyValue 解析位于 i 的 key = value，返回点分隔的键和值
+func (p *tomlParser) parseKeyValue(i int) ([]string, *docNode, error) {
+	keys, j, err := p.parseKey(i)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.byteAt(j) != '=' {
+		return nil, nil, p.errorf(j, "expected '=' after key")
+	}
+	node, err := p.parseValue(p.skipSpaces(j + 1))
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, node, nil
+}
+
+// parseKey 解析位于 i 的键（可以用 . 分隔，每一段是裸键或引号字符串），返回键之后跳过空白的位置
+func (p *tomlParser) parseKey(i int) ([]string, int, error) {
+	var keys []string
+	for {
+		i = p.skipSpaces(i)
+		switch p.byteAt(i) {
+		case '"', '\'':
+			n, err := p.parseString(i)
+			if err != nil {
+				return nil, 0, err
+			}
+			if n.style == '|' {
+				return nil, 0, p.errorf(i, "multi-line string cannot be a key")
+			}
+			keys = append(keys, n.value.(string))
+			i = n.end
+		default:
+			j := i
+			for j < len(p.data) && tomlBareKeyRe.MatchString(p.data[j:j+1]) {
+				j++
+			}
+			if j == i {
+				return nil, 0, p.errorf(i, "invalid key")
+			}
+			keys = append(keys, p.data[i:j])
+			i = j
+		}
+		i = p.skipSpaces(i)
+		if p.byteAt(i) != '.' {
+			return keys, i, nil
+		}
+		i++
+	}
+}
+
+// parseValue 解析位于 i 的值：字符串、数组、内联表、布尔值、数字或日期时间（日期时间作为字符串）
+func (p *tomlParser) parseValue(i int) (*docNode, error) {
+	switch p.byteAt(i) {
+	case '"', '\'':
+		return p.parseString(i)
+	case '[':
+		return p.parseArray(i)
+	case '{':
+		return p.parseInlineTable(i)
+	}
+
+	if m := tomlDateTimeRe.FindString(p.data[i:]); m != "" {
+		return &docNode{value: m, start: i, end: i + len(m)}, nil
+	}
+	j := i
+	for j < len(p.data) && !strings.ContainsRune(" \t\r\n,]}#", rune(p.data[j])) {
+		j++
+	}
+	token := p.data[i:j]
+	n := &docNode{start: i, end: j}
+	switch token {
+	case "true":
+		n.value = true
+	case "false":
+		n.value = false
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		// 无法用 JSON 表示，作为字符串处理（不会被当作数据提取）
+		n.value = token
+	default:
+		number := strings.ReplaceAll(token, "_", "")
+		var v float64
+		var err error
+		switch strings.ToLower(number[:min(len(number), 2)]) {
+		case "0x", "0o", "0b":
+			var whole int64
+			whole, err = strconv.ParseInt(number, 0, 64)
+			v = float64(whole)
+		default:
+			v, err = strconv.ParseFloat(number, 64)
+		}
+		if err != nil {
+			return nil, p.errorf(i, "invalid value %q", token)
+		}
+		n.value = v
+	}
+	return n, nil
+}
+
+// parseString 解析基本字符串、字面字符串（单引号）和它们用三个引号的多行形式
+func (p *tomlParser) parseString(i int) (*docNode, error) {
+	quote := p.data[i]
+	delim := p.data[i : i+1]
+	n := &docNode{style: quote, start: i}
+	if strings.HasPrefix(p.data[i:], strings.Repeat(delim, 3)) {
+		delim = strings.Repeat(delim, 3)
+		n.style = '|'
+	}
+
+	j := i + len(delim)
+	if n.style == '|' {
+		// 紧跟在开始引号之后的换行不属于字符串
+		if strings.HasPrefix(p.data[j:], "\r\n") {
+			j += 2
+		} else if p.byteAt(j) == '\n' {
+			j++
+		}
+	}
+
+	var b strings.Builder
+	for j < len(p.data) {
+		c := p.data[j]
+		switch {
+		case strings.HasPrefix(p.data[j:], delim):
+			// 多行字符串的结尾最多可以再有两个引号属于内容
+			end := j + len(delim)
+			for k := 0; n.style == '|' && k < 2 && p.byteAt(end) == quote; k++ {
+				b.WriteByte(quote)
+				end++
+			}
+			n.value, n.end = b.String(), end
+			return n, nil
+		case (c == '\r' || c == '\n') && n.style != '|':
+			return nil, p.errorf(i, "unterminated string")
+		case c == '\\' && quote == '"':
+			next, err := p.unescape(&b, j, n.style == '|')
+			if err != nil {
+				return nil, err
+			}
+			j = next
+		default:
+			b.WriteByte(c)
+			j++
+		}
+	}
+	return nil, p.errorf(i, "unterminated string")
+}
+
+// unescape 把位于 i 的基本字符串转义序列写入 b，返回转义序列之后的位置
+// 多行字符串中行尾的反斜杠去掉换行和下一行开头的空白
+func (p *tomlParser) unescape(b *strings.Builder, i int, multiline bool) (int, error) {
+	c := p.byteAt(i + 1)
+	if s, ok := tomlEscapes[c]; ok {
+		b.WriteString(s)
+		return i + 2, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if size > 0 && i+2+size <= len(p.data) {
+		r, err := strconv.ParseUint(p.data[i+2:i+2+size], 16, 32)
+		if err == nil && utf8.ValidRune(rune(r)) {
+			b.WriteRune(rune(r))
+			return i + 2 + size, nil
+		}
+	}
+	if multiline {
+		j := p.skipSpaces(i + 1)
+		if c := p.byteAt(j); c == '\r' || c == '\n' {
+			for j < len(p.data) && strings.ContainsRune(" \t\r\n", rune(p.data[j])) {
+				j++
+			}
+			return j, nil
+		}
+	}
+	return 0, p.errorf(i, "invalid escape sequence")
+}
+
+// parseArray 解析数组 [a, b]（可以跨行，允许注释和末尾的逗号）
+func (p *tomlParser) parseArray(i int) (*docNode, error) {
+	n := &docNode{kind: docSequence, start: i, inline: true}
+	for i = p.skipSpace(i + 1); ; i = p.skipSpace(i) {
+		if p.byteAt(i) == ']' {
+			n.end = i + 1
+			return n, nil
+		}
+		node, err := p.parseValue(i)
+		if err != nil {
+			return nil, err
+		}
+		n.entries = append(n.entries, docEntry{node: node, start: i, end: node.end})
+
+		i = p.skipSpace(node.end)
+		switch p.byteAt(i) {
+		case ',':
+			i++
+		case ']':
+		default:
+			return nil, p.errorf(i, "expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable 解析内联表 { k = v, ... }
+// 点分隔的键隐式创建的子表中的字段不删除
+func (p *tomlParser) parseInlineTable(i int) (*docNode, error) {
+	n := &docNode{kind: docMapping, start: i, inline: true}
+	for i = p.skipSpace(i + 1); ; i = p.skipSpace(i) {
+		if p.byteAt(i) == '}' {
+			n.end = i + 1
+			return n, nil
+		}
+		keys, node, err := p.parseKeyValue(i)
+		if err != nil {
+			return nil, err
+		}
+		e := docEntry{key: keys[len(keys)-1], node: node, start: i, end: node.end}
+		table := n
+		if len(keys) > 1 {
+			if table, err = p.table(n, keys[:len(keys)-1]); err != nil {
+				return nil, p.errorf(i, "%v", err)
+			}
+			e.start, e.end = -1, -1
+		}
+		table.entries = append(table.entries, e)
+
+		i = p.skipSpace(node.end)
+		switch p.byteAt(i) {
+		case ',':
+			i++
+		case '}':
+		default:
+			return nil, p.errorf(i, "expected ',' or '}' in inline table")
+		}
+	}
+}
+
+// finishLine 检查值之后的行内容为空或注释，并把 pos 移到下一行行首
+func (p *tomlParser) finishLine(i int) error {
+	i = p.skipSpaces(i)
+	if c := p.byteAt(i); c != 0 && c != '\r' && c != '\n' && c != '#' {
+		return p.errorf(i, "unexpected content after value")
+	}
+	p.pos = lineEnd(p.data, i)
+	return nil
+}
+
+// skipSpace 跳过数组和内联表中的空白、换行和注释
+func (p *tomlParser) skipSpace(i int) int {
+	for i < len(p.data) {
+		switch c := p.data[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '#':
+			i = lineEnd(p.data, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipSpaces 跳过行内的空格和制表符
+func (p *tomlParser) skipSpaces(i int) int {
+	for i < len(p.data) && (p.data[i] == ' ' || p.data[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// byteAt 返回位置 i 的字节，超出范围时返回 0
+func (p *tomlParser) byteAt(i int) byte {
+	if i < len(p.data) {
+		return p.data[i]
+	}
+	return 0
+}
+
+// encodeTOMLScalar 返回替换 TOML 值的内容：字符串尽量保持字面字符串的写法，否则写为基本字符串
+// （JSON 字符串也是合法的 TOML 基本字符串）；对象写为内联表
+func encodeTOMLScalar(n *docNode, value interface{}) string {
+	s, ok := value.(string)
+	switch {
+	case value == nil:
+		return `""`
+	case !ok:
+		var b strings.Builder
+		writeTOMLValue(&b, normalizeJSONValue(value))
+		return b.String()
+	case n.style == '\'' && !strings.ContainsAny(s, "'\r\n"):
+		return "'" + s + "'"
+	}
+	return string(encodeJSONValue(s))
+}
+
+// writeTOMLValue 把 JSON token 流中的下一个值写为 TOML 值，对象写为内联表，null 写为空字符串
+func writeTOMLValue(b *strings.Builder, dec *json.Decoder) {
+	tok, err := dec.Token()
+	if err != nil {
+		return
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		open, closing := "[", "]"
+		if t == '{' {
+			open, closing = "{ ", " }"
+		}
+		b.WriteString(open)
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if t == '{' {
+				key, _ := dec.Token()
+				name, _ := key.(string)
+				if !tomlBareKeyRe.MatchString(name) {
+					name = string(encodeJSONValue(name))
+				}
+				b.WriteString(name + " = ")
+			}
+			writeTOMLValue(b, dec)
+		}
+		dec.Token()
+		b.WriteString(closing)
+	case string:
+		b.Write(encodeJSONValue(t))
+	case json.Number:
+		b.WriteString(t.String())
+	case bool:
+		b.WriteString(strconv.FormatBool(t))
+	default:
+		b.WriteString(`""`)
+	}
+}
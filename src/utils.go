@@ -1,10 +1,18 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -12,6 +20,18 @@ import (
 
 var imageCounter uint64
 
+// 提取文件的命名方式（--naming）
+const (
+	namingTimestamp = "timestamp" // 时间戳 + 计数器: 20251224195004631_1.png
+	namingHash      = "hash"      // 内容的 SHA-256: 6b7fa434f92a8b80.png
+)
+
+var (
+	namingMode = namingTimestamp // 由 --naming 设置
+	hashLength = 16              // 由 --hash-length 设置，按内容命名时使用的哈希长度
+	fixedClock *time.Time        // 由 --clock 设置，固定文件名中使用的时间
+)
+
 // isImageFile 检查文件是否是图片文件
 func isImageFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -135,9 +155,9 @@ func isBase64File(filename string) bool {
 			base64Sample = contentStr[:sampleSize]
 		}
 
-		// 尝试 base64 解码
-		decoded, err := base64.StdEncoding.DecodeString(base64Sample)
-		if err != nil {
+		// 尝试 base64 解码（兼容 URL 安全、无填充和换行的 base64）
+		decoded := decodeBase64Prefix(base64Sample)
+		if decoded == nil {
 			return false
 		}
 
@@ -148,15 +168,40 @@ func isBase64File(filename string) bool {
 	return false
 }
 
+// currentTime 返回生成文件名时使用的当前时间（指定了 --clock 时返回固定的时间）
+func currentTime() time.Time {
+	if fixedClock != nil {
+		return *fixedClock
+	}
+	return time.Now()
+}
+
+// parseClock 解析 --clock 参数：RFC 3339 时间（如 2025-01-01T00:00:00Z）或 Unix 时间戳（秒）
+func parseClock(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --clock value %q (expected RFC 3339 time or Unix seconds)", value)
+	}
+	return t, nil
+}
+
 // generateTimestampFilename 生成带时间戳的唯一文件名
 func generateTimestampFilename(ext string) string {
-	now := time.Now()
+	now := currentTime()
 	timestamp := now.Format("20060102150405") // 格式: YYYYMMDDHHMMSS
 	millis := now.UnixMilli() % 1000
 	counter := atomic.AddUint64(&imageCounter, 1)
 	return fmt.Sprintf("%s%03d_%d%s", timestamp, millis, counter, ext)
 }
 
+// generateHashFilename 根据内容的 SHA-256 生成文件名（截取前 hashLength 个十六进制字符）
+func generateHashFilename(sum, ext string) string {
+	return sum[:min(hashLength, len(sum))] + ext
+}
+
 // generateNumberedFilename 生成带序号的文件名（.1.png, .2.png 等）
 func generateNumberedFilename(filename string) string {
 	ext := filepath.Ext(filename)
@@ -172,6 +217,16 @@ func generateNumberedFilename(filename string) string {
 	}
 }
 
+// sortedKeys 返回按字典序排序的对象键
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // min 返回两个整数中的较小值
 func min(a, b int) int {
 	if a < b {
@@ -180,56 +235,227 @@ func min(a, b int) int {
 	return b
 }
 
-// saveBase64Image 保存 base64 编码的图片到文件
-func saveBase64Image(base64Data, mimeType, outputDir string) (string, error) {
-	// 解码 base64
-	imageData, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %w", err)
+// blobHeadSize 写入文件时保留的数据开头字节数（用于检测类型和读取图片尺寸）
+const blobHeadSize = 64 * 1024
+
+// blobWriter 在写入文件的同时统计数据大小、SHA-256，并保留开头部分字节
+type blobWriter struct {
+	hash hash.Hash
+	size int64
+	head []byte
+}
+
+// Write 实现 io.Writer 接口
+func (w *blobWriter) Write(p []byte) (int, error) {
+	w.hash.Write(p)
+	w.size += int64(len(p))
+	if room := blobHeadSize - len(w.head); room > 0 {
+		w.head = append(w.head, p[:min(room, len(p))]...)
 	}
+	return len(p), nil
+}
 
-	// 根据 mime_type 确定文件扩展名
-	ext := ".png"
-	if strings.Contains(mimeType, "jpeg") || strings.Contains(mimeType, "jpg") {
-		ext = ".jpg"
-	} else if strings.Contains(mimeType, "png") {
-		ext = ".png"
-	} else if strings.Contains(mimeType, "gif") {
-		ext = ".gif"
-	} else if strings.Contains(mimeType, "webp") {
-		ext = ".webp"
+// savedBlob 描述一个已保存到磁盘的数据块
+type savedBlob struct {
+	Path     string // 相对路径，形如 decoded/xxx.png（输出目录名 + 文件名）
+	Name     string // 相对于输出目录的路径（使用 / 分隔）
+	FullPath string // 文件的完整路径
+	MimeType string
+	Bytes    int64
+	SHA256   string
+	Encoding string // 输入使用的 base64 变体（见 base64Variant）
+	Width    int    // 图片宽度，无法识别时为 0
+	Height   int    // 图片高度，无法识别时为 0
+}
+
+// saveBase64Image 保存 base64 编码的数据（图片、音频、PDF 等）到文件
+// src 描述数据在原文档中的位置，用于生成 manifest
+func saveBase64Image(base64Data, mimeType, outputDir string, src blobSource) (savedBlob, error) {
+	return saveEncodedStream(strings.NewReader(base64Data), base64Payload, mimeType, outputDir, src)
+}
+
+// saveEncodedStream 边解码边写入文件，不在内存中保留完整的解码结果
+// encoding 是 r 中数据的编码方式（base64 或百分号编码）
+func saveEncodedStream(r io.Reader, encoding payloadEncoding, mimeType, outputDir string, src blobSource) (savedBlob, error) {
+	// scan 模式只统计数据，不写入文件
+	if scanning {
+		return scanBlob(r, encoding, mimeType, src)
 	}
 
-	// 生成文件名
-	filename := generateTimestampFilename(ext)
+	// 根据 mime_type 确定文件扩展名
+	ext := extensionForMimeType(mimeType)
 
 	// 确定输出目录
-	var decodedDir string
-	if outputDir != "" {
-		// 使用指定的输出目录
-		decodedDir = outputDir
-	} else {
-		// 使用默认的 decoded 目录
-		cwd, err := os.Getwd()
-		if err != nil {
-			return "", fmt.Errorf("failed to get current directory: %w", err)
+	decodedDir, err := resolveDecodedDir(outputDir)
+	if err != nil {
+		return savedBlob{}, err
+	}
+
+	// 批处理记录：按记录标识创建子目录或添加文件名前缀
+	var subdir, prefix string
+	if currentRecord != "" {
+		if recordNaming == recordNamingPrefix {
+			prefix = currentRecord + "_"
+		} else {
+			subdir = currentRecord
 		}
-		decodedDir = filepath.Join(cwd, "decoded")
 	}
+	targetDir := filepath.Join(decodedDir, subdir)
 
 	// 创建目录（如果不存在）
-	if err := os.MkdirAll(decodedDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create output directory: %w", err)
+	trackCreatedDirs(targetDir)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return savedBlob{}, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// 先写入临时文件，解码完成并确定文件名后再重命名（按内容命名时需要先算出哈希）
+	file, err := os.CreateTemp(targetDir, ".b64-*.tmp")
+	if err != nil {
+		return savedBlob{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	tmpPath := file.Name()
+	stats := &blobWriter{hash: sha256.New()}
+	dec := newPayloadDecoder(r, encoding)
+	if _, err := io.Copy(io.MultiWriter(file, stats), dec); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		// 数据被截断时解码器返回 io.ErrUnexpectedEOF，同样属于无效的 base64
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return savedBlob{}, fmt.Errorf("failed to decode base64: %w", err)
+		}
+		return savedBlob{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return savedBlob{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	sum := hex.EncodeToString(stats.hash.Sum(nil))
+
+	// 生成文件名
+	var filename string
+	switch {
+	case nameTemplate != "":
+		// 模板可以包含子目录，记录前缀加在最后一层文件名上
+		rendered := renderNameTemplate(nameTemplate, templateValues{
+			src:      src,
+			mimeType: mimeType,
+			ext:      ext,
+			sum:      sum,
+			index:    atomic.AddUint64(&imageCounter, 1),
+		})
+		dir, base := path.Split(rendered)
+		filename = dir + prefix + base
+	case src.Name != "":
+		// Data URL 的 name/filename 参数，没有扩展名时根据 MIME 类型添加
+		filename = prefix + src.Name
+		if path.Ext(src.Name) == "" {
+			filename += ext
+		}
+	case namingMode == namingHash:
+		filename = prefix + generateHashFilename(sum, ext)
+	default:
+		filename = prefix + generateTimestampFilename(ext)
 	}
 
 	// 构建完整文件路径
-	fullPath := filepath.Join(decodedDir, filename)
+	fullPath := filepath.Join(targetDir, filepath.FromSlash(filename))
+	trackCreatedDirs(filepath.Dir(fullPath))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		os.Remove(tmpPath)
+		return savedBlob{}, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// CreateTemp 创建的文件权限为 0600，改为与其他输出文件一致
+	os.Chmod(tmpPath, 0644)
+
+	reuse := false
+	if nameTemplate == "" && src.Name == "" && namingMode == namingHash {
+		// 按内容命名时，同名文件的内容必然相同，直接复用已有文件
+		_, err := os.Stat(fullPath)
+		reuse = err == nil
+	} else {
+		// 文件名冲突（模板、name 参数，或 --clock 时上一次运行生成的文件）：
+		// 内容相同时复用，否则使用带序号的文件名，不覆盖已有文件
+		fullPath, reuse = resolveNameCollision(fullPath, sum)
+	}
+
+	if reuse {
+		os.Remove(tmpPath)
+	} else {
+		if err := os.Rename(tmpPath, fullPath); err != nil {
+			os.Remove(tmpPath)
+			return savedBlob{}, fmt.Errorf("failed to write file: %w", err)
+		}
+		trackCreatedFile(fullPath)
+	}
+	extractedCount++
+
+	// 相对于输出目录的路径
+	relPath, err := filepath.Rel(decodedDir, fullPath)
+	if err != nil {
+		return savedBlob{}, fmt.Errorf("failed to resolve output path: %w", err)
+	}
 
-	// 保存文件
-	if err := os.WriteFile(fullPath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+	blob := savedBlob{
+		Path:     filepath.Join(filepath.Base(decodedDir), relPath),
+		Name:     filepath.ToSlash(relPath),
+		FullPath: fullPath,
+		MimeType: normalizeMimeType(mimeType),
+		Bytes:    stats.size,
+		SHA256:   sum,
+		Encoding: dec.Encoding(),
 	}
+	blob.Width, blob.Height = imageDimensions(stats.head)
+	recordManifestEntry(src, blob, stats.head)
 
-	// 返回相对路径
-	return filepath.Join(filepath.Base(decodedDir), filename), nil
+	return blob, nil
+}
+
+// resolveNameCollision 处理文件名冲突：依次检查 name.png、name.1.png、name.2.png ...
+// 找到内容相同的文件时返回该文件并复用，否则返回第一个不存在的文件名
+func resolveNameCollision(fullPath, sum string) (string, bool) {
+	ext := filepath.Ext(fullPath)
+	nameWithoutExt := strings.TrimSuffix(fullPath, ext)
+
+	candidate := fullPath
+	for counter := 1; ; counter++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate, false
+		}
+		if existing, err := fileSHA256(candidate); err == nil && existing == sum {
+			return candidate, true
+		}
+		candidate = fmt.Sprintf("%s.%d%s", nameWithoutExt, counter, ext)
+	}
+}
+
+// fileSHA256 计算文件内容的 SHA-256
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolveDecodedDir 确定提取图片的输出目录（默认为当前目录下的 decoded）
+func resolveDecodedDir(outputDir string) (string, error) {
+	if outputDir != "" {
+		// 使用指定的输出目录
+		return outputDir, nil
+	}
+
+	// 使用默认的 decoded 目录
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %w", err)
+	}
+	return filepath.Join(cwd, "decoded"), nil
 }
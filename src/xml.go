@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// xsiNamespace XML Schema instance 的命名空间（xsi:type）
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// xmlMimeNamespaces xmlmime 的命名空间，SOAP 中 base64Binary 元素用 xmime:contentType 属性声明数据的 MIME 类型
+var xmlMimeNamespaces = map[string]bool{
+	"http://www.w3.org/2005/05/xmlmime": true,
+	"http://www.w3.org/2004/11/xmlmime": true,
+}
+
+// xmlTextEscaper 转义替换到元素内容中的文本
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// xmlElement 扫描 XML 时尚未结束的一个元素
+type xmlElement struct {
+	name         xml.Name
+	start        int             // 开始标签的起始位置
+	contentStart int             // 开始标签之后的位置
+	text         strings.Builder // 元素的文本内容（已解码实体和 CDATA）
+	mixed        bool            // 包含子元素、注释或处理指令，不是只有文本的元素
+	binary       bool            // xsi:type 为 base64Binary
+	mimeType     string          // xmime:contentType 声明的 MIME 类型
+}
+
+// xmlRewriter 保留原始字节的 XML 改写器：属性和 <style> 按 HTML 模式改写，只有文本的元素中的 base64 数据替换为文件引用
+type xmlRewriter struct {
+	markupRewriter
+	outputDir string
+	plist     bool // 根元素是 <plist>（Apple 属性列表）
+}
+
+// runXML 以 XML 处理输入（SVG、plist、SOAP 等）：提取属性、<style> 和元素内容中的 base64 数据，
+// 其他字节保持不变；无法解析时作为纯文本处理
+func runXML(data []byte, outputDir string, pretty bool) {
+	if replaceMode == replacePlaceholder {
+		fmt.Fprintf(os.Stderr, "Error: --replace-with placeholder is not supported for XML input\n")
+		os.Exit(exitInvalidInput)
+	}
+	runDocument(data, inputXML, outputDir, pretty)
+}
+
+// rewriteXML 用 encoding/xml 逐个读取 token，按 token 在输入中的字节范围改写：
+//   - 开始标签中的 Data URL 属性（SVG 的 <image href>、xlink:href）和 style 属性中的 url()
+//   - <style> 元素（SVG 的 CSS）中的 url()
+//   - 只有文本的元素：plist 的 <data>、xsi:type 为 base64Binary 或带有 xmime:contentType 的元素、
+//     内容是 Data URL 的元素，以及 --detect-bare 时内容是裸 base64 图片的元素
+//
+// 无法解析时输出警告并返回 errInvalidDocument
+func rewriteXML(data []byte, outputDir string) ([]byte, error) {
+	x := &xmlRewriter{outputDir: outputDir}
+	x.data = data
+	x.resolve = extractMarkupURL(outputDir, textSource)
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Entity = xml.HTMLEntity
+	// 只需要 token 在原始输入中的位置，非 UTF-8 的编码声明按原样读取（base64 数据都是 ASCII）
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var stack []*xmlElement
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: invalid xml (%v), processing as text\n", err)
+			return nil, errInvalidDocument
+		}
+		end := int(dec.InputOffset())
+
+		var parent *xmlElement
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if parent == nil {
+				x.plist = t.Name.Local == "plist"
+			} else {
+				parent.mixed = true
+			}
+			e := &xmlElement{name: t.Name, start: start, contentStart: end}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == xsiNamespace && attr.Name.Local == "type":
+					e.binary = attr.Value == "base64Binary" || strings.HasSuffix(attr.Value, ":base64Binary")
+				case xmlMimeNamespaces[attr.Name.Space] && attr.Name.Local == "contentType":
+					e.mimeType = strings.TrimSpace(attr.Value)
+				}
+			}
+			if _, _, err := x.scanTag(start); err != nil {
+				return nil, err
+			}
+			stack = append(stack, e)
+
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			if !parent.mixed {
+				if err := x.rewriteText(parent, start, end); err != nil {
+					return nil, err
+				}
+			}
+
+		case xml.CharData:
+			if parent == nil {
+				continue
+			}
+			if parent.name.Local == "style" {
+				if err := x.rewriteStyleBlock(start, end); err != nil {
+					return nil, err
+				}
+			}
+			parent.text.Write(t)
+
+		default:
+			// 注释、处理指令
+			if parent != nil {
+				parent.mixed = true
+			}
+		}
+	}
+	return applyByteEdits(data, x.edits), nil
+}
+
+// rewriteText 提取只有文本的元素中的数据，把元素内容替换为文件引用
+// contentEnd 和 end 是结束标签的起始和结束位置；plist 的 <data> 替换为 <string> 元素
+func (x *xmlRewriter) rewriteText(e *xmlElement, contentEnd, end int) error {
+	text := strings.TrimSpace(e.text.String())
+	if text == "" || e.name.Local == "style" {
+		return nil
+	}
+	plistData := x.plist && e.name.Local == "data"
+
+	src := textSource(e.contentStart)
+	var blob savedBlob
+	var err error
+	if u, ok := parseDataURL(text); ok {
+		if !isExtractableMimeType(u.MimeType) {
+			return nil
+		}
+		blob, err = saveDataURL(u, x.outputDir, src)
+	} else {
+		// base64Binary 的内容可以有空白和换行
+		payload := strings.Join(strings.Fields(text), "")
+		mimeType := e.mimeType
+		switch {
+		case mimeType != "":
+		case e.binary || plistData:
+			mimeType = "application/octet-stream"
+			if ext := detectImageType(decodeBase64Prefix(payload[:min(len(payload), bareSniffLength)])); ext != "" {
+				mimeType = mimeTypeForExtension(ext)
+			}
+		default:
+			if mimeType, ok = sniffBareBase64(payload); !ok {
+				return nil
+			}
+		}
+		if !isExtractableMimeType(mimeType) || !isBase64String(payload) {
+			return nil
+		}
+		blob, err = saveBase64Image(payload, mimeType, x.outputDir, src)
+	}
+
+	link := replacementText(blob)
+	if err != nil {
+		value, replaced, err := handleExtractError(src, err)
+		if err != nil || !replaced || !isRemoval(value) {
+			return err
+		}
+		link, plistData = "", false
+	}
+
+	if plistData && replaceMode != replaceRemove {
+		// 文件引用不再是 base64 数据，plist 中写为字符串
+		x.edits = append(x.edits, byteEdit{
+			start:       int64(e.start),
+			end:         int64(end),
+			replacement: []byte("<string>" + xmlTextEscaper.Replace(link) + "</string>"),
+		})
+		return nil
+	}
+	x.edits = append(x.edits, byteEdit{
+		start:       int64(e.contentStart),
+		end:         int64(contentEnd),
+		replacement: []byte(xmlTextEscaper.Replace(link)),
+	})
+	return nil
+}
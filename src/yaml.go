@@ -0,0 +1,780 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlNumberRe 匹配 YAML 1.2 core schema 的数字
+var yamlNumberRe = regexp.MustCompile(`^(?:[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?|0x[0-9a-fA-F]+|0o[0-7]+)$`)
+
+// yamlBase64LineRe 匹配换行的 base64 数据的一行，第一行可以以 Data URL 的头部开始
+var yamlBase64LineRe = regexp.MustCompile(`^(?:(?i:data):[^,\s]*;base64,)?[A-Za-z0-9+/_-]+=*$`)
+
+// yamlEscapes 双引号标量中的单字符转义序列
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r",
+	'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// yamlParser 解析 YAML 文档并记录每个值的字节范围
+// 支持块映射和块序列、流式集合、普通/引号/块标量、锚点、标签和多文档；别名不展开
+type yamlParser struct {
+	data string
+	pos  int // 当前位置，解析完一个块节点后总是在行首
+}
+
+// parseYAML 解析 YAML 输入（可以包含多个用 --- 分隔的文档），返回每个文档的根节点
+func parseYAML(data []byte) ([]*docNode, error) {
+	p := &yamlParser{data: string(data)}
+	var docs []*docNode
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.data) {
+			return docs, nil
+		}
+
+		var node *docNode
+		var err error
+		switch {
+		case p.data[p.pos] == '%' || p.marker(p.pos, "..."):
+			// 指令（%YAML、%TAG）和文档结束标记
+			p.pos = lineEnd(p.data, p.pos)
+			continue
+		case p.marker(p.pos, "---"):
+			i := p.skipSpaces(p.pos + 3)
+			if p.restBlank(i) {
+				p.pos = lineEnd(p.data, p.pos)
+				continue
+			}
+			// 与 --- 在同一行的值，如 --- |
+			node, err = p.parseNode(i, -1, false)
+		default:
+			node, err = p.parseNode(p.pos+p.indent(p.pos), -1, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, node)
+
+		p.skipBlank()
+		if p.pos < len(p.data) && !p.marker(p.pos, "---") && !p.marker(p.pos, "...") {
+			return nil, p.errorf(p.pos, "unexpected content")
+		}
+	}
+}
+
+// errorf 返回带行号的解析错误
+func (p *yamlParser) errorf(i int, format string, args ...interface{}) error {
+	line := strings.Count(p.data[:i], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// parseBlock 解析从当前行开始、缩进大于 parent 的块节点；没有这样的行时返回位于 at 的空值
+// inMapping 为 true 时（映射的值）允许与父映射缩进相同的块序列
+func (p *yamlParser) parseBlock(at, parent int, inMapping bool) (*docNode, error) {
+	save := p.pos
+	p.skipBlank()
+	if p.pos < len(p.data) && !p.marker(p.pos, "---") && !p.marker(p.pos, "...") {
+		indent := p.indent(p.pos)
+		i := p.pos + indent
+		if indent > parent || indent == parent && inMapping && p.seqEntry(i) {
+			return p.parseNode(i, parent, inMapping)
+		}
+	}
+	p.pos = save
+	return &docNode{start: at, end: at}, nil
+}
+
+// parseNode 解析位于 i 的节点：块序列、块映射或标量/流式集合
+func (p *yamlParser) parseNode(i, parent int, inMapping bool) (*docNode, error) {
+	if p.seqEntry(i) {
+		return p.parseSequence(i)
+	}
+	if _, _, ok := p.mappingKey(i); ok {
+		return p.parseMapping(i)
+	}
+	return p.parseValue(i, parent, inMapping)
+}
+
+// parseMapping 解析从 i 开始的块映射，i 之后的键必须在行首并且缩进相同
+func (p *yamlParser) parseMapping(i int) (*docNode, error) {
+	col := i - p.lineStart(i)
+	n := &docNode{kind: docMapping, start: i, inline: p.compact(i)}
+	for {
+		key, after, ok := p.mappingKey(i)
+		if !ok {
+			return nil, p.errorf(i, "expected a mapping key")
+		}
+
+		var node *docNode
+		var err error
+		if j := p.skipSpaces(after); p.restBlank(j) {
+			p.pos = lineEnd(p.data, j)
+			node, err = p.parseBlock(after, col, true)
+		} else {
+			node, err = p.parseValue(j, col, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e := docEntry{key: key, node: node, start: p.lineStart(i), end: p.pos}
+		if n.inline {
+			e.start, e.end = i, node.end
+		}
+		n.entries = append(n.entries, e)
+		n.end = node.end
+
+		if !p.nextEntry(col) {
+			return n, nil
+		}
+		i = p.pos + col
+	}
+}
+
+// parseSequence 解析从 i 开始的块序列，i 之后的 - 必须在行首并且缩进相同
+func (p *yamlParser) parseSequence(i int) (*docNode, error) {
+	col := i - p.lineStart(i)
+	n := &docNode{kind: docSequence, start: i, inline: p.compact(i)}
+	for {
+		if !p.seqEntry(i) {
+			return nil, p.errorf(i, "expected a sequence entry")
+		}
+
+		var node *docNode
+		var err error
+		if j := p.skipSpaces(i + 1); p.restBlank(j) {
+			p.pos = lineEnd(p.data, j)
+			node, err = p.parseBlock(i+1, col, false)
+		} else {
+			node, err = p.parseNode(j, col, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e := docEntry{node: node, start: p.lineStart(i), end: p.pos}
+		if n.inline {
+			e.start, e.end = i, node.end
+		}
+		n.entries = append(n.entries, e)
+		n.end = node.end
+
+		// 与父映射缩进相同的序列在下一个键之前结束
+		save := p.pos
+		if !p.nextEntry(col) || !p.seqEntry(p.pos+col) {
+			p.pos = save
+			return n, nil
+		}
+		i = p.pos + col
+	}
+}
+
+// nextEntry 判断下一个内容行是否是缩进为 col 的同一集合的元素，是时 pos 移到该行行首
+func (p *yamlParser) nextEntry(col int) bool {
+	save := p.pos
+	p.skipBlank()
+	if p.pos < len(p.data) && !p.marker(p.pos, "---") && !p.marker(p.pos, "...") && p.indent(p.pos) == col {
+		return true
+	}
+	p.pos = save
+	return false
+}
+
+// compact 判断位于 i 的集合是否与所在的序列元素写在同一行（- key: value 或 - - item）
+func (p *yamlParser) compact(i int) bool {
+	return strings.TrimSpace(p.data[p.lineStart(i):i]) != ""
+}
+
+// parseValue 解析位于 i 的值（锚点和标签之后的标量或流式集合），值之后的行内容必须为空或注释
+// 标签为 !!binary 时去掉数据中的空白，并且值的范围包括标签（替换后的值不再是二进制数据）
+func (p *yamlParser) parseValue(i, parent int, inMapping bool) (*docNode, error) {
+	start := i
+	i, binary := p.properties(i)
+
+	var n *docNode
+	var end int
+	var err error
+	switch c := p.byteAt(i); {
+	case p.restBlank(i):
+		p.pos = lineEnd(p.data, i)
+		n, err = p.parseBlock(i, parent, inMapping)
+	case c == '|' || c == '>':
+		n, err = p.parseBlockScalar(i, parent)
+	case c == '*' || c == '[' || c == '{' || c == '"' || c == '\'':
+		n, end, err = p.parseFlowValue(i)
+		if err == nil {
+			err = p.finishLine(end)
+		}
+	default:
+		n = p.parsePlain(i, parent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := n.value.(string); ok && binary {
+		n.value = strings.Join(strings.Fields(s), "")
+		n.start = start
+	}
+	return n, nil
+}
+
+// properties 跳过位于 i 的锚点（&name）和标签（!tag），返回值的位置和是否有 !!binary 标签
+func (p *yamlParser) properties(i int) (int, bool) {
+	binary := false
+	for c := p.byteAt(i); c == '&' || c == '!'; c = p.byteAt(i) {
+		j := i
+		for j < len(p.data) && !isYAMLSpace(p.data[j]) && !strings.ContainsRune(",[]{}\r\n", rune(p.data[j])) {
+			j++
+		}
+		switch p.data[i:j] {
+		case "!!binary", "!<tag:yaml.org,2002:binary>":
+			binary = true
+		}
+		i = p.skipSpaces(j)
+	}
+	return i, binary
+}
+
+// finishLine 检查值之后的行内容为空或注释，并把 pos 移到下一行行首
+func (p *yamlParser) finishLine(i int) error {
+	i = p.skipSpaces(i)
+	if !p.restBlank(i) {
+		return p.errorf(i, "unexpected content after value")
+	}
+	p.pos = lineEnd(p.data, i)
+	return nil
+}
+
+// parsePlain 解析普通（无引号）标量，缩进大于 parent 的后续行是同一个标量的续行
+func (p *yamlParser) parsePlain(i, parent int) *docNode {
+	n := &docNode{start: i}
+	end, comment := p.plainLineEnd(i)
+	var b, joined strings.Builder
+	first := p.data[i:end]
+	b.WriteString(first)
+	joined.WriteString(first)
+	n.end = end
+	p.pos = lineEnd(p.data, i)
+
+	// 换行的 base64 数据（Data URL 或至少 64 个字符的行）直接连接，不插入空格
+	base64Lines := yamlBase64LineRe.MatchString(first) && (len(first) >= 64 || strings.Contains(first, ","))
+	lines := 1
+
+	// 续行之间用空格连接，空行保留为换行
+	for j, empty := p.pos, 0; !comment && j < len(p.data); {
+		indent := p.indent(j)
+		k := j + indent
+		if p.skipSpaces(k) >= p.contentEnd(j) {
+			empty++
+			j = lineEnd(p.data, j)
+			continue
+		}
+		if indent <= parent || p.data[k] == '#' || p.marker(j, "---") || p.marker(j, "...") {
+			break
+		}
+		if _, _, ok := p.mappingKey(k); ok {
+			break
+		}
+		if empty == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.Repeat("\n", empty))
+		end, comment = p.plainLineEnd(k)
+		line := p.data[k:end]
+		b.WriteString(line)
+		joined.WriteString(line)
+		base64Lines = base64Lines && empty == 0 && yamlBase64LineRe.MatchString(line) && !strings.Contains(line, ",")
+		lines++
+		n.end = end
+		j = lineEnd(p.data, k)
+		p.pos, empty = j, 0
+	}
+
+	if base64Lines && lines > 1 {
+		n.value = joined.String()
+		return n
+	}
+	n.value = resolvePlain(b.String())
+	return n
+}
+
+// plainLineEnd 返回普通标量在 i 所在行中的结束位置（不包括注释和末尾的空白），以及该行是否有注释
+func (p *yamlParser) plainLineEnd(i int) (int, bool) {
+	end := p.contentEnd(i)
+	comment := false
+	for j := i + 1; j < end; j++ {
+		if p.data[j] == '#' && isYAMLSpace(p.data[j-1]) {
+			end, comment = j, true
+			break
+		}
+	}
+	for end > i && isYAMLSpace(p.data[end-1]) {
+		end--
+	}
+	return end, comment
+}
+
+// parseBlockScalar 解析块标量（| 为字面量，> 为折叠），支持保留/去掉末尾换行（+/-）和缩进指示符
+// 值的范围从指示符到最后一个内容行的结尾
+func (p *yamlParser) parseBlockScalar(i, parent int) (*docNode, error) {
+	folded := p.data[i] == '>'
+	var chomp byte
+	explicit := 0
+	j := i + 1
+	for k := 0; k < 2; k++ {
+		switch c := p.byteAt(j); {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+			j++
+		case c >= '1' && c <= '9' && explicit == 0:
+			explicit = int(c - '0')
+			j++
+		}
+	}
+	if j = p.skipSpaces(j); !p.restBlank(j) {
+		return nil, p.errorf(j, "invalid block scalar header")
+	}
+
+	n := &docNode{style: '|', start: i, end: j}
+	indent := -1
+	if explicit > 0 {
+		indent = max(parent, 0) + explicit
+	}
+	var lines, blank []string // blank 为尚未确定是否属于内容的空行（可能有超过缩进的空格）
+	p.pos = lineEnd(p.data, j)
+	for k := p.pos; k < len(p.data); {
+		lineIndent := p.indent(k)
+		end := p.contentEnd(k)
+		if p.skipSpaces(k) >= end {
+			line := ""
+			if indent >= 0 && k+indent < end {
+				line = p.data[k+indent : end]
+			}
+			blank = append(blank, line)
+			k = lineEnd(p.data, k)
+			continue
+		}
+		if indent < 0 {
+			indent = lineIndent
+		}
+		if lineIndent < indent || indent <= parent || p.marker(k, "---") || p.marker(k, "...") {
+			break
+		}
+		lines = append(append(lines, blank...), p.data[k+indent:end])
+		blank = nil
+		n.end = end
+		k = lineEnd(p.data, k)
+		p.pos = k
+	}
+
+	var b strings.Builder
+	for idx, line := range lines {
+		if idx > 0 {
+			prev := lines[idx-1]
+			switch {
+			case !folded || prev == "" || moreIndented(prev) || moreIndented(line):
+				b.WriteByte('\n')
+			case line != "":
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	switch {
+	case chomp == '+':
+		b.WriteString(strings.Repeat("\n", len(blank)+1))
+	case chomp == 0 && len(lines) > 0:
+		b.WriteByte('\n')
+	}
+	if len(lines) > 0 {
+		n.indent = indent
+	}
+	n.value = b.String()
+	return n, nil
+}
+
+// moreIndented 判断折叠块标量中的行是否比其他行缩进更多（这样的行不折叠）
+func moreIndented(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+// parseFlowValue 解析流式上下文中位于 i 的值：流式集合、引号标量、别名或普通标量，返回值之后的位置
+func (p *yamlParser) parseFlowValue(i int) (*docNode, int, error) {
+	start := i
+	i, binary := p.properties(i)
+
+	var n *docNode
+	var end int
+	var err error
+	switch p.byteAt(i) {
+	case '[', '{':
+		n, end, err = p.parseFlow(i)
+	case '"', '\'':
+		n, end, err = p.parseQuoted(i)
+	case '*':
+		// 别名引用的值不重复处理
+		end = i + 1
+		for end < len(p.data) && !isYAMLSpace(p.data[end]) && !strings.ContainsRune(",[]{}\r\n", rune(p.data[end])) {
+			end++
+		}
+		n = &docNode{start: i, end: end}
+	default:
+		end = i
+		for end < len(p.data) {
+			c := p.data[end]
+			if strings.ContainsRune(",[]{}\r\n", rune(c)) ||
+				c == ':' && (end+1 >= len(p.data) || isYAMLSpace(p.data[end+1]) || strings.ContainsRune(",[]{}\r\n", rune(p.data[end+1]))) ||
+				c == '#' && end > i && isYAMLSpace(p.data[end-1]) {
+				break
+			}
+			end++
+		}
+		for end > i && isYAMLSpace(p.data[end-1]) {
+			end--
+		}
+		n = &docNode{start: i, end: end, value: resolvePlain(p.data[i:end])}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if s, ok := n.value.(string); ok && binary {
+		n.value = strings.Join(strings.Fields(s), "")
+		n.start = start
+	}
+	return n, end, nil
+}
+
+// parseFlow 解析流式集合 [a, b] 或 {k: v}（可以跨行），返回集合之后的位置
+func (p *yamlParser) parseFlow(i int) (*docNode, int, error) {
+	n := &docNode{kind: docSequence, start: i, inline: true}
+	closing := byte(']')
+	if p.data[i] == '{' {
+		n.kind, closing = docMapping, '}'
+	}
+
+	for i = p.skipFlowSpace(i + 1); ; i = p.skipFlowSpace(i) {
+		if i >= len(p.data) {
+			return nil, 0, p.errorf(n.start, "unterminated flow collection")
+		}
+		if p.data[i] == closing {
+			n.end = i + 1
+			return n, i + 1, nil
+		}
+
+		e := docEntry{start: i}
+		node, end, err := p.parseFlowValue(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		if colon := p.skipFlowSpace(end); n.kind == docMapping || p.byteAt(colon) == ':' {
+			// 映射的键之后没有 : 时值为 null；序列中的 key: value 是只有一个字段的映射
+			key := p.data[node.start:node.end]
+			if s, ok := node.value.(string); ok {
+				key = s
+			}
+			value := &docNode{start: colon, end: colon}
+			if p.byteAt(colon) == ':' {
+				value, end, err = p.parseFlowValue(p.skipFlowSpace(colon + 1))
+				if err != nil {
+					return nil, 0, err
+				}
+			}
+			if n.kind == docMapping {
+				e.key, node = key, value
+			} else {
+				node = &docNode{kind: docMapping, start: node.start, end: value.end, inline: true,
+					entries: []docEntry{{key: key, node: value, start: node.start, end: value.end}}}
+			}
+		}
+		e.node, e.end = node, node.end
+		n.entries = append(n.entries, e)
+
+		i = p.skipFlowSpace(end)
+		switch p.byteAt(i) {
+		case ',':
+			i++
+		case closing:
+		default:
+			return nil, 0, p.errorf(i, "expected ',' or '%c' in flow collection", closing)
+		}
+	}
+}
+
+// parseQuoted 解析单引号或双引号标量（可以跨行），返回结束引号之后的位置
+// 换行折叠为空格（空行保留为换行），双引号中支持 YAML 的转义序列
+func (p *yamlParser) parseQuoted(i int) (*docNode, int, error) {
+	quote := p.data[i]
+	var b strings.Builder
+	spaces := 0 // 尚未写入的空白（行尾的空白在折叠时去掉）
+	for j := i + 1; j < len(p.data); {
+		c := p.data[j]
+		switch {
+		case c == quote && quote == '\'' && p.byteAt(j+1) == '\'':
+			b.WriteString(p.data[j-spaces : j])
+			b.WriteByte('\'')
+			spaces = 0
+			j += 2
+		case c == quote:
+			b.WriteString(p.data[j-spaces : j])
+			return &docNode{value: b.String(), style: quote, start: i, end: j + 1}, j + 1, nil
+		case c == ' ' || c == '\t':
+			spaces++
+			j++
+		case c == '\r' || c == '\n':
+			spaces = 0
+			breaks := 0
+			for j = p.skipSpaces(lineEnd(p.data, j)); p.byteAt(j) == '\r' || p.byteAt(j) == '\n'; j = p.skipSpaces(lineEnd(p.data, j)) {
+				breaks++
+			}
+			if breaks == 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(strings.Repeat("\n", breaks))
+		case c == '\\' && quote == '"':
+			b.WriteString(p.data[j-spaces : j])
+			spaces = 0
+			next, err := p.unescape(&b, j)
+			if err != nil {
+				return nil, 0, err
+			}
+			j = next
+		default:
+			b.WriteString(p.data[j-spaces : j+1])
+			spaces = 0
+			j++
+		}
+	}
+	return nil, 0, p.errorf(i, "unterminated quoted scalar")
+}
+
+// unescape 把位于 i 的双引号转义序列写入 b，返回转义序列之后的位置
+func (p *yamlParser) unescape(b *strings.Builder, i int) (int, error) {
+	simple := map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r",
+		'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+		'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+	}
+	c := p.byteAt(i + 1)
+	if s, ok := simple[c]; ok {
+		b.WriteString(s)
+		return i + 2, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	switch {
+	case c == '\r' || c == '\n':
+		// 转义的换行：连接两行，不插入空格
+		return p.skipSpaces(lineEnd(p.data, i+1)), nil
+	case size > 0 && i+2+size <= len(p.data):
+		r, err := strconv.ParseUint(p.data[i+2:i+2+size], 16, 32)
+		if err == nil && utf8.ValidRune(rune(r)) {
+			b.WriteRune(rune(r))
+			return i + 2 + size, nil
+		}
+	}
+	return 0, p.errorf(i, "invalid escape sequence")
+}
+
+// mappingKey 判断位于 i 的内容是否是块映射的键（key: 或 "key":），返回键和冒号之后的位置
+func (p *yamlParser) mappingKey(i int) (string, int, bool) {
+	end := p.contentEnd(i)
+	if i >= end || strings.ContainsRune("[]{}#&*!|>%@`,?", rune(p.data[i])) || p.seqEntry(i) {
+		return "", 0, false
+	}
+	if c := p.data[i]; c == '"' || c == '\'' {
+		n, j, err := p.parseQuoted(i)
+		if err != nil || j > end {
+			return "", 0, false
+		}
+		j = p.skipSpaces(j)
+		if p.byteAt(j) != ':' || j+1 < end && !isYAMLSpace(p.data[j+1]) {
+			return "", 0, false
+		}
+		return n.value.(string), j + 1, true
+	}
+	for j := i; j < end; j++ {
+		switch {
+		case p.data[j] == '#' && j > i && isYAMLSpace(p.data[j-1]):
+			return "", 0, false
+		case p.data[j] == ':' && (j+1 == end || isYAMLSpace(p.data[j+1])):
+			return strings.TrimRight(p.data[i:j], " \t"), j + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// seqEntry 判断位于 i 的内容是否是块序列的元素（- 之后是空白或行尾）
+func (p *yamlParser) seqEntry(i int) bool {
+	return p.byteAt(i) == '-' && (i+1 >= len(p.data) || isYAMLSpace(p.data[i+1]) || p.data[i+1] == '\r' || p.data[i+1] == '\n')
+}
+
+// marker 判断行首 i 是否是文档标记 --- 或 ...
+func (p *yamlParser) marker(i int, m string) bool {
+	if !strings.HasPrefix(p.data[i:], m) {
+		return false
+	}
+	c := p.byteAt(i + 3)
+	return c == 0 || isYAMLSpace(c) || c == '\r' || c == '\n'
+}
+
+// skipBlank 跳过空行和只有注释的行，pos 移到下一个内容行的行首
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.data) && p.restBlank(p.skipSpaces(p.pos)) {
+		p.pos = lineEnd(p.data, p.pos)
+	}
+}
+
+// skipFlowSpace 跳过流式集合中的空白、换行和注释
+func (p *yamlParser) skipFlowSpace(i int) int {
+	for i < len(p.data) {
+		switch c := p.data[i]; {
+		case isYAMLSpace(c) || c == '\r' || c == '\n':
+			i++
+		case c == '#':
+			i = lineEnd(p.data, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipSpaces 跳过行内的空格和制表符
+func (p *yamlParser) skipSpaces(i int) int {
+	for i < len(p.data) && isYAMLSpace(p.data[i]) {
+		i++
+	}
+	return i
+}
+
+// restBlank 判断 i 之后的行内容是否为空或注释
+func (p *yamlParser) restBlank(i int) bool {
+	c := p.byteAt(i)
+	return c == 0 || c == '\r' || c == '\n' || c == '#'
+}
+
+// indent 返回行首 i 所在行的缩进（空格数）
+func (p *yamlParser) indent(i int) int {
+	n := 0
+	for i+n < len(p.data) && p.data[i+n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// lineStart 返回 i 所在行的行首
+func (p *yamlParser) lineStart(i int) int {
+	return strings.LastIndexByte(p.data[:i], '\n') + 1
+}
+
+// contentEnd 返回 i 所在行的内容结束位置（不包括换行符）
+func (p *yamlParser) contentEnd(i int) int {
+	end := lineEnd(p.data, i)
+	if end > i && p.data[end-1] == '\n' {
+		end--
+	}
+	if end > i && p.data[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// byteAt 返回位置 i 的字节，超出范围时返回 0
+func (p *yamlParser) byteAt(i int) byte {
+	if i < len(p.data) {
+		return p.data[i]
+	}
+	return 0
+}
+
+// isYAMLSpace 判断是否是行内的空白
+func isYAMLSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// resolvePlain 按 YAML 1.2 core schema 解析普通标量的类型：null、布尔值、数字，其他为字符串
+func resolvePlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if !yamlNumberRe.MatchString(s) {
+		return s
+	}
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		n, _ := strconv.ParseInt(s, 0, 64)
+		return float64(n)
+	case strings.HasPrefix(s, "0o"):
+		n, _ := strconv.ParseInt("0"+s[2:], 8, 64)
+		return float64(n)
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// encodeYAMLScalar 返回替换 YAML 标量的内容：字符串保持原来的引号方式，块标量中的文字（包含换行）仍写为块标量，可以时不加引号，
+// 否则写为双引号字符串（JSON 字符串也是合法的 YAML）；对象写为流式映射，删除的数组元素写为 null
+func encodeYAMLScalar(n *docNode, value interface{}) string {
+	s, ok := value.(string)
+	switch {
+	case value == nil:
+		return "null"
+	case !ok:
+		return string(encodeJSONValue(value))
+	case n.style == '|' && n.indent > 0 && strings.Contains(s, "\n"):
+		if block, ok := yamlLiteralBlock(s, n.indent); ok {
+			return block
+		}
+	case n.style == '\'' && !strings.ContainsAny(s, "\r\n"):
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	case n.style != '"' && yamlPlainSafe(s):
+		return s
+	}
+	return string(encodeJSONValue(s))
+}
+
+// yamlLiteralBlock 把多行字符串写为字面量块标量（| 或 |-），内容行使用原来的缩进 indent，替换从指示符到最后一个内容行的范围
+// 无法不加缩进指示符和 |+ 准确表示的字符串（首行以空格开头、末尾有多个换行、包含 \r）返回 false
+func yamlLiteralBlock(s string, indent int) (string, bool) {
+	header := "|"
+	content := strings.TrimSuffix(s, "\n")
+	if content == s {
+		header = "|-"
+	}
+	if content == "" || content[0] == ' ' || strings.HasSuffix(content, "\n") || strings.Contains(s, "\r") {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	prefix := strings.Repeat(" ", indent)
+	for _, line := range strings.Split(content, "\n") {
+		b.WriteByte('\n')
+		if line != "" {
+			b.WriteString(prefix + line)
+		}
+	}
+	return b.String(), true
+}
+
+// yamlPlainSafe 判断字符串是否可以不加引号写为普通标量（在块和流式上下文中都不会被误解析）
+func yamlPlainSafe(s string) bool {
+	if s == "" || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` \t") || strings.ContainsAny(s, ",[]{}\r\n\t") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") || strings.HasSuffix(s, " ") {
+		return false
+	}
+	_, isString := resolvePlain(s).(string)
+	return isString
+}
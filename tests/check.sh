@@ -0,0 +1,80 @@
+#!/bin/bash
+# 用测试文件检查各处理模式的输出与 expected/ 中的期望输出一致（先运行 ./build.sh）
+cd "$(dirname "$0")" || exit 1
+b64="$(cd .. && pwd)/b64"
+tests="$(pwd)"
+
+status=0
+
+# check 期望输出 输入文件 [参数...]：在临时目录中处理输入文件，比较标准输出
+check() {
+	expected=$1
+	input=$2
+	shift 2
+	tmp=$(mktemp -d)
+	# 固定时间戳，输出目录使用相对路径，使文件名与期望输出一致
+	(cd "$tmp" && "$b64" --clock 2026-01-01T00:00:00Z -o decoded "$@" "$tests/$input" > out)
+	if diff -u "expected/$expected" "$tmp/out"; then
+		echo "ok   $expected"
+	else
+		echo "FAIL $expected"
+		status=1
+	fi
+	rm -rf "$tmp"
+}
+
+# roundtrip 输入文件 [参数...]：提取后用 b64 inline 还原，结果必须与输入完全相同
+roundtrip() {
+	input=$1
+	shift
+	tmp=$(mktemp -d)
+	(cd "$tmp" && "$b64" -o decoded "$@" "$tests/$input" > out && "$b64" inline -o decoded out > restored)
+	if cmp -s "$input" "$tmp/restored"; then
+		echo "ok   $input (inline $*)"
+	else
+		echo "FAIL $input (inline $*)"
+		status=1
+	fi
+	rm -rf "$tmp"
+}
+
+# YAML/TOML：锚点、块/流式集合、多文档、点分键、内联表
+for input in test_config.yaml test_config.toml; do
+	for mode in rel object remove; do
+		check "test_config.$mode.${input##*.}" "$input" --replace-with "$mode"
+	done
+done
+
+# 邮件：CRLF 换行、cid: 和 Content-Location 引用、附件
+for mode in rel remove; do
+	check "test_message.$mode.eml" test_message.eml --replace-with "$mode"
+done
+
+# HTML：srcset、<source>、style 属性和 <style> 中的 url()，跳过注释、<script>、<textarea> 和 <title>
+for mode in rel remove; do
+	check "test_page.$mode.html" test_page.html --replace-with "$mode"
+done
+
+# Markdown：标题、尖括号、引用式图片的定义（包括目标在下一行）和内嵌 HTML 标签
+for mode in rel placeholder remove; do
+	check "test_doc.$mode.md" test_doc.md --replace-with "$mode"
+done
+
+# 日志：JSON 转义的 /（一层和两层）、转义的 \n 换行、Markdown 图片和未转义的 Data URL
+for mode in rel remove; do
+	check "test_app.$mode.log" test_app.log --replace-with "$mode"
+done
+
+# 流式 JSON：字段顺序、大整数和小数、不转义的 <、>、&，data 在 mime_type 之前的结构化格式，嵌入的 JSON 字符串
+check test_api.stream.json test_api.json --stream
+check test_api.stream-pretty.json test_api.json --stream --pretty
+check test_api.stream-remove.json test_api.json --stream --replace-with remove
+
+# 保留原始字节的 JSON 改写：只替换提取出的字符串，b64 inline 还原后与输入完全相同
+check test_api.preserve.json test_api.json --preserve
+check test_api.preserve-remove.json test_api.json --preserve --replace-with remove
+for mode in rel object; do
+	roundtrip test_api.json --preserve --replace-with "$mode"
+done
+
+exit $status